@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRecord is the change one run made to one aircraft
+type AuditRecord struct {
+	RunID      string      `json:"runId" bson:"runId"`
+	AircraftID string      `json:"id" bson:"aircraftId"`
+	Before     *AuditState `json:"before" bson:"before"`
+	After      *AuditState `json:"after" bson:"after"`
+	Method     string      `json:"method" bson:"method"` // How the manufacturer was matched
+	At         time.Time   `json:"at" bson:"at"`
+}
+
+type AuditState struct {
+	Title string `json:"title" bson:"title"`
+	// The manufacturer as stored, a string or an ObjectID, so reverting
+	// puts back the same type
+	Manufacturer any `json:"manufacturer" bson:"manufacturer"`
+	// There was no manufacturer field at all, as opposed to an empty one
+	NoManufacturer bool `json:"noManufacturer,omitempty" bson:"noManufacturer,omitempty"`
+}
+
+// newAuditRecord records the aircraft as it was read and as res leaves it,
+// with manufacturer the reference that was written
+func newAuditRecord(runID string, a Aircraft, res Resolution, manufacturer any) AuditRecord {
+	before := &AuditState{Title: a.Title}
+	before.Manufacturer, before.NoManufacturer = storedManufacturer(a)
+
+	return AuditRecord{
+		RunID:      runID,
+		AircraftID: a.ID,
+		Before:     before,
+		After:      &AuditState{Title: res.Title, Manufacturer: manufacturer},
+		Method:     res.Method,
+		At:         time.Now().UTC(),
+	}
+}
+
+// storedManufacturer is the aircraft's manufacturer field with the type it
+// was read with, and whether it was missing
+func storedManufacturer(a Aircraft) (any, bool) {
+	if a.raw == nil {
+		return a.Manufacturer, a.Manufacturer == ""
+	}
+	v, err := a.raw.LookupErr("manufacturer")
+	if err != nil {
+		return nil, true
+	}
+	var value any
+	if err := v.Unmarshal(&value); err != nil {
+		return a.Manufacturer, false
+	}
+
+	return value, false
+}
+
+// auditFile appends audit records to a file, one JSON document per line, so
+// successive runs build up a single trail
+type auditFile struct {
+	file *os.File
+	enc  *json.Encoder
+}
+
+func openAuditFile(path string) (*auditFile, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditFile{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// add writes the record straight to the file, so it survives the run dying
+func (f *auditFile) add(r AuditRecord) error {
+	return f.enc.Encode(r)
+}
+
+func (f *auditFile) Close() error {
+	return f.file.Close()
+}
+
+// revertUpdate puts back exactly what the record says was there before
+func revertUpdate(r AuditRecord) bson.M {
+	if r.Before.NoManufacturer {
+		return bson.M{"$set": bson.M{"title": r.Before.Title}, "$unset": bson.M{"manufacturer": ""}}
+	}
+
+	return bson.M{"$set": bson.M{"title": r.Before.Title, "manufacturer": r.Before.Manufacturer}}
+}
+
+// revertRun puts back the title and manufacturer every aircraft had before
+// the given run, as recorded in the audit collection. All of the run's
+// records are checked before anything is changed.
+func revertRun(ctx context.Context, aircraft, audit *mongo.Collection, runID string, report *Report) error {
+	opts := mongoOptions.Find().SetSort(bson.D{{Key: "at", Value: 1}})
+	opCtx, cancel := opContext(ctx)
+	cursor, err := audit.Find(opCtx, bson.M{"runId": runID}, opts)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	var records []AuditRecord
+	for nextDoc(ctx, cursor) {
+		var r AuditRecord
+		if err := cursor.Decode(&r); err != nil {
+			return err
+		}
+		records = append(records, r)
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no audit records for run %s in %s", runID, audit.Name())
+	}
+	incomplete := 0
+	for _, r := range records {
+		if r.AircraftID == "" || r.Before == nil {
+			incomplete++
+		}
+	}
+	if incomplete > 0 {
+		return fmt.Errorf("%d of the %d audit records for run %s are incomplete", incomplete, len(records), runID)
+	}
+
+	restored := make(map[string]bool)
+	for _, r := range records {
+		// an aircraft changed twice in one run (e.g. under -watch) goes
+		// back to how it was before the first change
+		if restored[r.AircraftID] {
+			continue
+		}
+		restored[r.AircraftID] = true
+		report.Processed++
+		opCtx, cancel := opContext(ctx)
+		_, err := aircraft.UpdateOne(opCtx, bson.M{"_id": r.AircraftID}, revertUpdate(r))
+		cancel()
+		if err = acknowledged(err); err != nil {
+			report.Errors.add(r.AircraftID, stageUpdate, err)
+			continue
+		}
+		report.Updated++
+	}
+
+	return nil
+}
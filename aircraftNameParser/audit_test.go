@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAuditRecordRevertsStoredManufacturer(t *testing.T) {
+	oid := primitive.NewObjectID()
+	tests := []struct {
+		name string
+		doc  bson.D
+		want bson.M
+	}{
+		{"objectid", bson.D{{Key: "manufacturer", Value: oid}}, bson.M{"$set": bson.M{"title": "Boeing 737", "manufacturer": oid}}},
+		{"empty", bson.D{{Key: "manufacturer", Value: ""}}, bson.M{"$set": bson.M{"title": "Boeing 737", "manufacturer": ""}}},
+		{"missing", bson.D{}, bson.M{"$set": bson.M{"title": "Boeing 737"}, "$unset": bson.M{"manufacturer": ""}}},
+	}
+	for _, tt := range tests {
+		raw, err := bson.Marshal(append(bson.D{{Key: "_id", Value: "1"}, {Key: "title", Value: "Boeing 737"}}, tt.doc...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := Aircraft{ID: "1", Title: "Boeing 737", raw: raw}
+		record := newAuditRecord("run", a, Resolution{Title: "737", Manufacturer: "boeing"}, "boeing")
+
+		// as revertRun reads it back
+		data, err := bson.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored AuditRecord
+		if err := bson.Unmarshal(data, &stored); err != nil {
+			t.Fatal(err)
+		}
+		if got := revertUpdate(stored); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: revert %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
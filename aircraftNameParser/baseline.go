@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// loadBaseline reads resolutions saved from an earlier -output=ndjson run,
+// accepting a JSON array as well, keyed by aircraft ID
+func loadBaseline(path string) (map[string]Resolution, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var resolutions []Resolution
+	if first, err := peekNonSpace(reader); err == nil && first == '[' {
+		err = json.NewDecoder(reader).Decode(&resolutions)
+		if err != nil {
+			return nil, configErrorf("baseline %s: %w", path, err)
+		}
+	} else {
+		decoder := json.NewDecoder(reader)
+		for {
+			var res Resolution
+			err := decoder.Decode(&res)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, configErrorf("baseline %s: %w", path, err)
+			}
+			resolutions = append(resolutions, res)
+		}
+	}
+
+	baseline := make(map[string]Resolution, len(resolutions))
+	for _, res := range resolutions {
+		baseline[res.ID] = res
+	}
+
+	return baseline, nil
+}
+
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, r.UnreadByte()
+		}
+	}
+}
+
+// diffBaseline resolves every aircraft and prints those whose manufacturer
+// or title differs from the baseline, which isolates the effect of an edit to
+// the manufacturers list. Nothing is written. It returns the number printed.
+func diffBaseline(aircraft []Aircraft, matcher Matcher, baseline map[string]Resolution, color bool, out io.Writer) int {
+	changed := 0
+	for _, a := range aircraft {
+		res := matcher.resolve(a)
+		before, ok := baseline[a.ID]
+		switch {
+		case !ok:
+			fmt.Fprintf(out, "%s: not in baseline, now %s\n", a.ID, describe(res, strconv.Quote(res.Title)))
+		case before.Manufacturer != res.Manufacturer || before.Title != res.Title:
+			was, now := titleDiff(before.Title, res.Title, color)
+			fmt.Fprintf(out, "%s: %s -> %s\n", a.ID, describe(before, was), describe(res, now))
+		default:
+			continue
+		}
+		changed++
+	}
+
+	return changed
+}
+
+// describe the resolution, with its already quoted title
+func describe(res Resolution, title string) string {
+	if !res.Matched() {
+		return "unmatched " + title
+	}
+
+	return res.Manufacturer + " " + title
+}
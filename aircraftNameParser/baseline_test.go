@@ -0,0 +1,18 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffBaselineKeepsCleanAircraft(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t, Manufacturer{ID: "boeing", Name: "Boeing"})}
+	a := Aircraft{ID: "1", Manufacturer: "boeing", Title: "Boeing 737"}
+	// what -output=ndjson records for it
+	baseline := map[string]Resolution{"1": {ID: "1", OriginalTitle: a.Title, Title: a.Title, Manufacturer: "boeing", AlreadyClean: true}}
+
+	var out strings.Builder
+	if changed := diffBaseline([]Aircraft{a}, m, baseline, false, &out); changed != 0 {
+		t.Errorf("diffBaseline reported %d changed: %s", changed, out.String())
+	}
+}
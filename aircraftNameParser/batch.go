@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// batcher queues in-place updates and sends them as unordered bulk writes.
+// The batch size adapts to how fast the cluster takes them: a flush slower
+// than slow halves it, and one well under slow doubles it again, up to max.
+type batcher struct {
+	size int
+	max  int
+	slow time.Duration
+
+	ids    []string
+	models []mongo.WriteModel
+}
+
+func newBatcher(size int, slow time.Duration) *batcher {
+	return &batcher{size: size, max: size, slow: slow}
+}
+
+func (b *batcher) add(id string, update bson.M) {
+	b.ids = append(b.ids, id)
+	b.models = append(b.models, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": id}).SetUpdate(update))
+}
+
+func (b *batcher) full() bool {
+	return len(b.models) >= b.size
+}
+
+// flush writes the queued updates, returning how many succeeded and the
+// error for each aircraft that didn't
+func (b *batcher) flush(ctx context.Context, collection *mongo.Collection) (int, map[string]error) {
+	if len(b.models) == 0 {
+		return 0, nil
+	}
+	ids := b.ids
+	start := time.Now()
+	opCtx, cancel := opContext(ctx)
+	_, err := collection.BulkWrite(opCtx, b.models, mongoOptions.BulkWrite().SetOrdered(false))
+	cancel()
+	err = acknowledged(err)
+	b.adapt(time.Since(start))
+	b.ids, b.models = nil, nil
+
+	failed := make(map[string]error)
+	var bulkErr mongo.BulkWriteException
+	switch {
+	case err == nil:
+	case errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil:
+		for _, we := range bulkErr.WriteErrors {
+			failed[ids[we.Index]] = we
+		}
+	default:
+		// nothing says which writes made it
+		for _, id := range ids {
+			failed[id] = err
+		}
+	}
+
+	return len(ids) - len(failed), failed
+}
+
+func (b *batcher) adapt(took time.Duration) {
+	switch {
+	case took > b.slow && b.size > 1:
+		b.size = max(b.size/2, 1)
+		log.Printf("bulk write of %d took %s, batch size down to %d", len(b.models), took.Round(time.Millisecond), b.size)
+	case took < b.slow/4 && b.size < b.max:
+		b.size = min(b.size*2, b.max)
+		log.Printf("bulk write of %d took %s, batch size up to %d", len(b.models), took.Round(time.Millisecond), b.size)
+	}
+}
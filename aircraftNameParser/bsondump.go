@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// bsonDump writes resolved aircraft documents for importing elsewhere: one
+// canonical extended JSON document per line for mongoimport, or raw BSON
+// for mongorestore when the file is named *.bson
+type bsonDump struct {
+	file *os.File // nil when dumping to stdout
+	w    *bufio.Writer
+	raw  bool
+}
+
+// newBSONDump creates the dump at path, "-" for stdout
+func newBSONDump(path string, stdout io.Writer) (*bsonDump, error) {
+	if path == "-" {
+		return &bsonDump{w: bufio.NewWriter(stdout)}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bsonDump{file: f, w: bufio.NewWriter(f), raw: filepath.Ext(path) == ".bson"}, nil
+}
+
+// write the aircraft as it looks once the resolution is applied
+func (d *bsonDump) write(w *writer, a Aircraft, res Resolution) error {
+	doc, err := resolvedDocument(w, a, res)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if d.raw {
+		data, err = bson.Marshal(doc)
+	} else {
+		data, err = bson.MarshalExtJSON(doc, true, false)
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	_, err = d.w.Write(data)
+
+	return err
+}
+
+// Close flushes the dump and closes its file
+func (d *bsonDump) Close() error {
+	err := d.w.Flush()
+	if d.file != nil {
+		if cerr := d.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// resolvedDocument is the aircraft's document as it was read, with every
+// field and type, and the writer's update applied on top, so manufacturer
+// references get the type they're stored with too
+func resolvedDocument(w *writer, a Aircraft, res Resolution) (bson.D, error) {
+	update, err := w.update(res)
+	if err != nil {
+		return nil, err
+	}
+	raw := a.raw
+	if raw == nil {
+		raw, err = bson.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	set := update["$set"].(bson.M)
+	// In a fixed order, so dumps of the same data are identical
+	for _, field := range []string{"manufacturer", "title", "model", "titleParentheticals"} {
+		if value, ok := set[field]; ok {
+			doc = setField(doc, field, value)
+		}
+	}
+	if add, ok := update["$addToSet"].(bson.M); ok {
+		doc = setField(doc, w.arrayField, add[w.arrayField].(bson.M)["$each"])
+	}
+
+	return doc, nil
+}
+
+// setField replaces field in doc, appending it when it isn't there
+func setField(doc bson.D, field string, value any) bson.D {
+	for i := range doc {
+		if doc[i].Key == field {
+			doc[i].Value = value
+			return doc
+		}
+	}
+
+	return append(doc, bson.E{Key: field, Value: value})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestResolvedDocumentKeepsStoredFields(t *testing.T) {
+	id := primitive.NewObjectID()
+	registered := primitive.NewDateTimeFromTime(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: id},
+		{Key: "title", Value: "Boeing 737"},
+		{Key: "registeredAt", Value: registered},
+		{Key: "seats", Value: int32(189)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manufacturer := primitive.NewObjectID()
+	a := Aircraft{ID: id.Hex(), Title: "Boeing 737", raw: raw}
+	res := Resolution{ID: a.ID, Title: "737", Manufacturer: manufacturer.Hex()}
+
+	doc, err := resolvedDocument(&writer{objectIDs: true}, a, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]any)
+	for _, e := range doc {
+		got[e.Key] = e.Value
+	}
+	want := map[string]any{"_id": id, "title": "737", "registeredAt": registered, "seats": int32(189), "manufacturer": manufacturer}
+	if len(got) != len(want) {
+		t.Errorf("got fields %v, want %v", got, want)
+	}
+	for field, value := range want {
+		if got[field] != value {
+			t.Errorf("%s = %#v, want %#v", field, got[field], value)
+		}
+	}
+}
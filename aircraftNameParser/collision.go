@@ -0,0 +1,111 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// collision is a pair of manufacturers whose names a title could plausibly
+// match either of
+type collision struct {
+	A, B     Manufacturer
+	Relation string // "prefix", "substring" or "edit distance N"
+	Aircraft int    // Aircraft whose title matched both
+}
+
+// findCollisions pairs up manufacturers where one name starts with or
+// contains the other, ignoring case, or the two are at most maxDistance
+// edits apart
+func findCollisions(manufacturers []Manufacturer, maxDistance int) []collision {
+	var collisions []collision
+	for i, a := range manufacturers {
+		for _, b := range manufacturers[i+1:] {
+			x, y := strings.ToLower(a.Name), strings.ToLower(b.Name)
+			if x == "" || y == "" {
+				continue
+			}
+			relation := ""
+			switch {
+			case strings.HasPrefix(x, y) || strings.HasPrefix(y, x):
+				relation = "prefix"
+			case strings.Contains(x, y) || strings.Contains(y, x):
+				relation = "substring"
+			default:
+				if d := editDistance(x, y); d <= maxDistance {
+					relation = fmt.Sprintf("edit distance %d", d)
+				}
+			}
+			if relation != "" {
+				collisions = append(collisions, collision{A: a, B: b, Relation: relation})
+			}
+		}
+	}
+
+	return collisions
+}
+
+// editDistance is the Levenshtein distance between a and b, in bytes
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// reportCollisions counts, for every colliding pair, the aircraft whose
+// title matched both manufacturers, and prints the pairs. It returns the
+// number of aircraft that matched at least one pair.
+func reportCollisions(aircraft []Aircraft, matcher Matcher, maxDistance int, out io.Writer) int {
+	collisions := findCollisions(matcher.Manufacturers, maxDistance)
+	byPair := make(map[[2]string]*collision, len(collisions))
+	for i := range collisions {
+		c := &collisions[i]
+		byPair[[2]string{c.A.ID, c.B.ID}] = c
+		byPair[[2]string{c.B.ID, c.A.ID}] = c
+	}
+
+	ambiguous := 0
+	for _, a := range aircraft {
+		title, _ := matcher.truncate(matcher.normalize(a.Title))
+		candidates := matcher.findCandidates(title)
+		hit := false
+		for i, x := range candidates {
+			for _, y := range candidates[i+1:] {
+				if c, ok := byPair[[2]string{x.Manufacturer.ID, y.Manufacturer.ID}]; ok {
+					c.Aircraft++
+					hit = true
+				}
+			}
+		}
+		if hit {
+			ambiguous++
+		}
+	}
+
+	slices.SortStableFunc(collisions, func(x, y collision) int {
+		return cmp.Compare(y.Aircraft, x.Aircraft)
+	})
+	for _, c := range collisions {
+		fmt.Fprintf(out, "%q (%s) and %q (%s): %s, %d aircraft matched both\n", c.A.Name, c.A.ID, c.B.Name, c.B.ID, c.Relation, c.Aircraft)
+	}
+	fmt.Fprintf(out, "colliding pairs: %d\n", len(collisions))
+
+	return ambiguous
+}
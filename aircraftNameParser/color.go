@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// -color settings
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+var colorModes = []string{colorAuto, colorAlways, colorNever}
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// useColor reports whether title diffs written to out are colored. In auto
+// mode that's only for a terminal, and not when NO_COLOR is set.
+func useColor(mode string, out io.Writer) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// titleDiff quotes before and after like %q does. With color, the text only
+// before has is red and the text only after has is green; the rest is the
+// whole words they share at either end.
+func titleDiff(before, after string, color bool) (string, string) {
+	if !color {
+		return strconv.Quote(before), strconv.Quote(after)
+	}
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	// whole words, so "Airbus A320" -> "A320" loses "Airbus ", not "irbus A"
+	for prefix > 0 && !(wordBoundary(before, prefix) && wordBoundary(after, prefix)) {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix && before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !(wordBoundary(before, len(before)-suffix) && wordBoundary(after, len(after)-suffix)) {
+		suffix--
+	}
+
+	return highlight(before, prefix, suffix, ansiRed), highlight(after, prefix, suffix, ansiGreen)
+}
+
+// wordBoundary reports whether s[:i] and s[i:] don't split a word
+func wordBoundary(s string, i int) bool {
+	return i == 0 || i == len(s) || s[i-1] == ' ' || s[i] == ' '
+}
+
+// highlight quotes s with everything between its first prefix and last
+// suffix bytes in the given color
+func highlight(s string, prefix, suffix int, color string) string {
+	middle := s[prefix : len(s)-suffix]
+	if middle == "" {
+		return strconv.Quote(s)
+	}
+
+	return `"` + unquoted(s[:prefix]) + color + unquoted(middle) + ansiReset + unquoted(s[len(s)-suffix:]) + `"`
+}
+
+// unquoted is s escaped like strconv.Quote, without the quotes
+func unquoted(s string) string {
+	q := strconv.Quote(s)
+
+	return q[1 : len(q)-1]
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// combinedRegex finds every manufacturer name in a title with a single scan,
+// instead of one strings.Index per manufacturer. It's a drop-in for the exact
+// method for manufacturers without a pattern and finds what findExact would.
+//
+// Like findExact, only names of several words need to be whole words; a
+// single word matches anywhere, as the parser always has, so "AirbusA320"
+// still loses its manufacturer and the lost code check is what catches the
+// damage. The word method is there for titles that need more care.
+//
+// It isn't behind a flag: BenchmarkFindCandidates has the per-manufacturer
+// loop ahead, about 1.0ms against 1.35ms a pass over 210 manufacturers,
+// and only level from 500 to 1000. Go's regexp runs a long alternation of literals as an NFA,
+// which costs more per byte than one strings.Index per name. The benchmark
+// and TestCombinedRegexMatchesLoop keep it honest for another try.
+type combinedRegex struct {
+	re *regexp.Regexp
+	// The other names that can start inside each name, at the offset they'd
+	// start at, e.g. "de Havilland" at 0 and "Canada" at 13 for "de
+	// Havilland Canada". The regexp doesn't report matches that overlap.
+	overlaps map[string][]overlap
+}
+
+// overlap is a name that could start offset bytes into another
+type overlap struct {
+	offset int
+	name   string
+}
+
+// newCombinedRegex builds one alternation of all the names. Go's regexp
+// prefers the earlier alternative among those starting at the same place, so
+// ordering them longest first reports "de Havilland Canada" rather than "de
+// Havilland", and overlaps gives the others.
+func newCombinedRegex(manufacturers []Manufacturer) *combinedRegex {
+	c := &combinedRegex{overlaps: make(map[string][]overlap)}
+	var names []string
+	for _, mf := range manufacturers {
+		if mf.pattern != nil || mf.Name == "" || slices.Contains(names, mf.Name) {
+			continue
+		}
+		names = append(names, mf.Name)
+	}
+	if len(names) == 0 {
+		return c
+	}
+	slices.SortStableFunc(names, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+		for _, other := range names {
+			for offset := range len(name) {
+				rest := name[offset:]
+				if other == name && offset == 0 || len(other) > len(rest) && !strings.HasPrefix(other, rest) || len(other) <= len(rest) && !strings.HasPrefix(rest, other) {
+					continue
+				}
+				c.overlaps[name] = append(c.overlaps[name], overlap{offset: offset, name: other})
+			}
+		}
+	}
+	c.re = regexp.MustCompile(strings.Join(quoted, "|"))
+
+	return c
+}
+
+// find returns, for every name in masked, where findExact would find it,
+// so long as that place fits the mode. It's one scan of masked; the names
+// that may start inside each match are checked where they would start.
+func (c *combinedRegex) find(m Matcher, masked string) map[string]int {
+	found := make(map[string]int)
+	if c.re == nil {
+		return found
+	}
+	for _, loc := range c.re.FindAllStringIndex(masked, -1) {
+		longest := masked[loc[0]:loc[1]]
+		c.record(m, masked, found, longest, loc[0])
+		for _, o := range c.overlaps[longest] {
+			if start := loc[0] + o.offset; strings.HasPrefix(masked[start:], o.name) {
+				c.record(m, masked, found, o.name, start)
+			}
+		}
+	}
+
+	return found
+}
+
+// record notes that name is in masked at start if it's the first place it's
+// allowed at
+func (c *combinedRegex) record(m Matcher, masked string, found map[string]int, name string, start int) {
+	if first, ok := found[name]; ok && first <= start {
+		return
+	}
+	end := start + len(name)
+	if strings.Contains(name, " ") && !isWordBoundary(masked, start, end) {
+		// as in findExact
+		return
+	}
+	if m.allowedAt(masked, start, end) {
+		found[name] = start
+	}
+}
+
+// exactCandidate is the exact method's candidate for mf, out of what find
+// found
+func exactCandidate(mf Manufacturer, found map[string]int) (candidate, bool) {
+	start, ok := found[mf.Name]
+	if !ok || mf.pattern != nil {
+		return candidate{}, false
+	}
+
+	return candidate{Manufacturer: mf, Start: start, End: start + len(mf.Name), Method: methodExact}, true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// combinedFixture is a manufacturer list with the awkward cases, names that
+// start with or contain others, a duplicate name, an exclude and a pattern,
+// padded out to the size of a real one, and titles to match against it
+func combinedFixture(t testing.TB) ([]Manufacturer, []string) {
+	manufacturers := []Manufacturer{
+		{ID: "dhc", Name: "de Havilland Canada"},
+		{ID: "dh", Name: "de Havilland"},
+		{ID: "canada", Name: "Canada"},
+		{ID: "airbus", Name: "Airbus", ExcludePatterns: []string{"Anti-Airbus"}},
+		{ID: "airbus-sas", Name: "Airbus"},
+		{ID: "air", Name: "Air"},
+		{ID: "boeing", Name: "Boeing"},
+		{ID: "mcdonnell", Name: "McDonnell Douglas"},
+		{ID: "douglas", Name: "Douglas"},
+		{ID: "cessna", Name: "Cessna", Pattern: `^Cessna (?P<model>\d+)`},
+	}
+	for i := range 200 {
+		manufacturers = append(manufacturers, Manufacturer{ID: fmt.Sprintf("maker%d", i), Name: fmt.Sprintf("Maker %03d Aviation", i)})
+	}
+	for i := range manufacturers {
+		if err := manufacturers[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	titles := []string{
+		"de Havilland Canada DHC-6",
+		"de Havilland Canadair",
+		"de Havilland DH.106 Comet",
+		"Canada de Havilland Canada",
+		"Airbus A320",
+		"Anti-Airbus A320",
+		"AirbusA320",
+		"Air Tractor",
+		"Boeing 747 by Airbus",
+		"Airbus sold to Boeing",
+		"Boeing Boeing 747",
+		"MD-11 McDonnell Douglas",
+		"McDonnell Douglasair",
+		"Douglas DC-3",
+		"Cessna 172 Skyhawk",
+		"Cessna Citation",
+		"A320 (Airbus)",
+		"Maker 007 Aviation",
+		"Maker 007 Aviation Airbus",
+		"Maker 0070 Aviation",
+		"",
+	}
+	for i := range 50 {
+		titles = append(titles, fmt.Sprintf("Model %d Maker %03d Aviation", i, i*3))
+	}
+
+	return manufacturers, titles
+}
+
+func TestCombinedRegexMatchesLoop(t *testing.T) {
+	manufacturers, titles := combinedFixture(t)
+	for _, mode := range matchModes {
+		for _, rules := range [][]string{nil, {methodExact}} {
+			loop := Matcher{Manufacturers: manufacturers, Mode: mode, Rules: rules}
+			combined := loop
+			combined.combined = newCombinedRegex(manufacturers)
+			for _, title := range titles {
+				a := Aircraft{ID: "1", Title: title}
+				want, got := loop.resolve(a), combined.resolve(a)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s mode, rules %v, %q: combined %+v, loop %+v", mode, rules, title, got, want)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkFindCandidates(b *testing.B) {
+	manufacturers, titles := combinedFixture(b)
+	loop := Matcher{Manufacturers: manufacturers, Rules: []string{methodExact}}
+	combined := loop
+	combined.combined = newCombinedRegex(manufacturers)
+	for _, bm := range []struct {
+		name string
+		m    Matcher
+	}{{"loop", loop}, {"combined", combined}} {
+		b.Run(bm.name, func(b *testing.B) {
+			for b.Loop() {
+				for _, title := range titles {
+					bm.m.findCandidates(title)
+				}
+			}
+		})
+	}
+}
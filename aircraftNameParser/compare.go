@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// compareWithReference checks what a run would leave every aircraft as
+// against the document with the same _id in the reference collection,
+// reporting each manufacturer or title that differs. Nothing is written. It
+// returns the number of mismatching aircraft.
+func compareWithReference(ctx context.Context, reference *mongo.Collection, aircraft []Aircraft, outcome func(Aircraft) Aircraft, out io.Writer) (int, error) {
+	expected, err := loadReference(ctx, reference)
+	if err != nil {
+		return 0, err
+	}
+
+	return compareAircraft(aircraft, expected, reference.Name(), outcome, out), nil
+}
+
+// loadReference reads the reference collection by _id
+func loadReference(ctx context.Context, reference *mongo.Collection) (map[string]Aircraft, error) {
+	opCtx, cancel := opContext(ctx)
+	cursor, err := reference.Find(opCtx, bson.D{{}})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	byID := make(map[string]Aircraft)
+	for nextDoc(ctx, cursor) {
+		var e Aircraft
+		if err := cursor.Decode(&e); err != nil {
+			return nil, err
+		}
+		byID[e.ID] = e
+	}
+
+	return byID, cursor.Err()
+}
+
+func compareAircraft(aircraft []Aircraft, expected map[string]Aircraft, name string, outcome func(Aircraft) Aircraft, out io.Writer) int {
+	mismatches := 0
+	for _, a := range aircraft {
+		got := outcome(a)
+		e, ok := expected[a.ID]
+		if !ok {
+			fmt.Fprintf(out, "%s: missing from %s\n", a.ID, name)
+			mismatches++
+			continue
+		}
+		if got.Manufacturer == e.Manufacturer && got.Title == e.Title {
+			continue
+		}
+		mismatches++
+		if got.Manufacturer != e.Manufacturer {
+			fmt.Fprintf(out, "%s: manufacturer %q, reference has %q\n", a.ID, got.Manufacturer, e.Manufacturer)
+		}
+		if got.Title != e.Title {
+			fmt.Fprintf(out, "%s: title %q, reference has %q\n", a.ID, got.Title, e.Title)
+		}
+	}
+
+	return mismatches
+}
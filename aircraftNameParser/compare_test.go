@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompareAircraft(t *testing.T) {
+	m := Matcher{
+		Manufacturers: testManufacturers(t, Manufacturer{ID: "airbus", Name: "Airbus"}, Manufacturer{ID: "md", Name: "MD"}),
+		StripPrefixes: []string{"The"},
+	}
+	p := &processor{cfg: Config{Strict: true}, matcher: m}
+	aircraft := []Aircraft{
+		// unmatched, the stored title is what's compared, not the normalized one
+		{ID: "1", Title: "The  Cessna 172"},
+		{ID: "2", Title: "Airbus A320"},
+		// skipped under -strict for losing its own code, so left as it is
+		{ID: "3", Icao: "MD11", Title: "MD11 freighter"},
+	}
+	expected := map[string]Aircraft{
+		"1": {ID: "1", Title: "The  Cessna 172"},
+		"2": {ID: "2", Manufacturer: "airbus", Title: "A320"},
+		"3": {ID: "3", Title: "MD11 freighter"},
+	}
+	var out strings.Builder
+	if n := compareAircraft(aircraft, expected, "reference", p.outcome, &out); n != 0 {
+		t.Errorf("%d mismatches:\n%s", n, out.String())
+	}
+
+	expected["2"] = Aircraft{ID: "2", Manufacturer: "airbus", Title: "A321"}
+	if n := compareAircraft(aircraft, expected, "reference", p.outcome, io.Discard); n != 1 {
+		t.Errorf("%d mismatches, want the changed title", n)
+	}
+}
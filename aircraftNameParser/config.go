@@ -0,0 +1,620 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+type Config struct {
+	MongoURI string // Connection string, which loadConfig takes from MONGODB_URL_FILE or MONGODB_URL
+	Database string // Database holding the aircraft, which loadConfig takes from MONGO_DB
+
+	// Where -interactive reads its answers, os.Stdin when nil
+	Stdin io.Reader
+	// Where streamed output and, when nothing is streamed, messages go,
+	// os.Stdout when nil
+	Stdout io.Writer
+	// Where messages go while Stdout carries streamed output, os.Stderr
+	// when nil
+	Stderr io.Writer
+
+	WriteConcern    string // Write concern for updates: "majority", a node count, or "" for the cluster default
+	ReadPref        string // Where read-only runs read from: primary, secondary or nearest
+	ConnectRetries  int    // Extra attempts at connecting before giving up
+	Strict          bool   // Skip changes that look suspicious instead of only reporting them
+	DryRun          bool   // Resolve manufacturers without writing anything back
+	Output          string // Per-aircraft output format, "" for none or "ndjson"
+	Interactive     bool   // Ask on stdin which manufacturer to use for ambiguous titles
+	Stats           bool   // Include memory usage in the summary
+	LengthHistogram bool   // Include title lengths before and after stripping in the summary
+	MaxErrorDetails int    // Errors reported in full; the rest are only counted
+	SummaryJSON     string // File the run's Report is written to as JSON
+	ReportHTML      string // File a dry run's proposed changes are written to as an HTML page
+	Color           string // Whether title diffs are colored: auto, always or never
+	BSONDump        string // File resolved aircraft are written to for mongoimport or mongorestore, "-" for stdout
+
+	StripPrefixes       []string // Noise words removed from the start of titles, e.g. "The", "Ex-"
+	MatchMode           string   // Where in the title manufacturers are matched: anywhere, prefix or suffix
+	Connectors          []string // Words removed in front of a stripped trailing manufacturer
+	CanonicalTitle      bool     // Rewrite titles as "<canonical manufacturer name> <model>"
+	MaxTitleLength      int      // Match in and store at most this many bytes of title, 0 for no limit
+	Phonetic            bool     // Match names that sound alike when nothing else matched
+	StripParentheticals bool     // Remove "(...)" clauses from stored titles, keeping them in titleParentheticals
+	MatchRules          []string // Match methods to use, in the order they're tried; all but phonetic when empty
+	// Two lists of match rules to resolve every aircraft with and compare,
+	// read-only
+	CompareRules [][]string
+
+	TitleRegex       string // Only process aircraft whose title matches this
+	ServerSideFilter bool   // Apply TitleRegex as a $regex in the query instead of after fetching
+	OnlyEmpty        bool   // Only fetch aircraft whose manufacturer is missing, null or ""
+
+	Discover          bool   // Suggest manufacturers from the leading words of unmatched titles, read-only
+	DiscoverTop       int    // Number of suggestions -discover prints
+	Baseline          string // Earlier -output=ndjson results to diff against instead of writing
+	CompareCollection string // Reference collection to verify results against instead of writing
+	VerifyRefs        bool   // Report aircraft whose manufacturer ID isn't a known manufacturer, read-only
+	CollisionReport   bool   // Report manufacturers with confusable names and the aircraft matching both, read-only
+	CollisionDistance int    // Names at most this many edits apart count as confusable
+	Probe             int    // Resolve this many random aircraft and print the results, read-only
+	UpsertCollection  string // Collection to upsert results into, keyed by UpsertKey, instead of updating aircraft
+	UpsertKey         string // Aircraft field (bson name) identifying documents in UpsertCollection
+
+	Manufacturers          string // Manufacturers file, manufacturers.json(.gz) when empty
+	UseEmbedded            bool   // Use the built-in manufacturers instead of a file, which is also the fallback when there's none
+	ManufacturerAsObjectID bool   // Write manufacturer references as ObjectIDs instead of strings
+	LenientManufacturers   bool   // Skip invalid manufacturers entries with a warning instead of failing
+	ManufacturerArrayField string // Array field every matched manufacturer is added to instead of setting manufacturer
+	Overrides              string // JSON file mapping aircraft IDs to the manufacturer IDs they're assigned regardless of title
+	ICAOManufacturers      string // JSON file mapping ICAO type designators to manufacturer IDs, to cross-check matches
+
+	AuditCollection string // Collection recording each in-place update, so a run can be reverted
+	AuditFile       string // JSON lines file each in-place update is appended to
+	RevertRun       string // Run ID whose changes are undone from the audit collection
+
+	Optimistic bool // Only update aircraft whose title hasn't changed since it was read
+
+	OpTimeout time.Duration // Longest a single database operation may take, 0 for no limit
+
+	BatchSize int           // Send in-place updates as bulk writes of up to this many, 0 to update one at a time
+	BatchSlow time.Duration // Bulk writes slower than this halve the batch size
+
+	MaxUpdates     int    // Most aircraft a run may update, 0 for no limit
+	MaxUpdatesMode string // What happens to a run over MaxUpdates, see maxUpdatesModes
+
+	GenerateFixtures   int     // Number of synthetic aircraft to generate instead of processing any
+	FixturesFile       string  // JSON file the fixtures are written to
+	FixturesCollection string  // Collection the fixtures are inserted into
+	FixturesNoise      float64 // Share of fixture titles given a typo or odd casing
+	FixturesSeed       uint64  // Seed making the fixtures reproducible
+
+	NormalizeOnly    bool   // Only clean up titles, without matching or touching manufacturers
+	TitleCase        string // How -normalize-only recases titles, see caseModes
+	CleanPunctuation bool   // Have -normalize-only tidy up stray and repeated punctuation
+
+	Incremental bool // Only process aircraft past the checkpoint the last incremental run left in parser_state
+
+	Watch             bool   // Keep resolving aircraft as they are inserted, via a change stream
+	WatchTitleUpdates bool   // Also resolve aircraft whose title is updated while watching
+	WatchResumeFile   string // Where the change stream's resume token is kept between runs
+
+	writeConcern *writeconcern.WriteConcern // Parsed from WriteConcern
+	titleRegex   *regexp.Regexp             // Compiled from TitleRegex
+}
+
+// ConfigError is a problem with how the tool was set up, as opposed to
+// something that went wrong during the run
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func configErrorf(format string, args ...any) error {
+	return &ConfigError{Err: fmt.Errorf(format, args...)}
+}
+
+func loadConfig() (Config, error) {
+	var cfg Config
+	// "majority" waits for the write to reach most of the replica set, which
+	// is the slowest but survives a primary failover. "1" only waits for the
+	// primary, and "0" doesn't wait at all, so failed updates go unreported.
+	flag.StringVar(&cfg.WriteConcern, "write-concern", "", "write concern for updates (majority, 1, 0, ...); defaults to the cluster default")
+	flag.StringVar(&cfg.ReadPref, "read-preference", defaultReadPref, "where read-only runs (-dry-run, -discover, -baseline, -compare-collection, -verify-refs, -collision-report, -probe, -compare-rules) read from: primary, secondary or nearest")
+	flag.DurationVar(&cfg.OpTimeout, "op-timeout", 30*time.Second, "fail any single database operation that takes longer than this, 0 for no limit")
+	flag.IntVar(&cfg.ConnectRetries, "connect-retries", 0, "times to retry connecting to mongo, with backoff, before giving up")
+	flag.BoolVar(&cfg.Strict, "strict", false, "skip suspicious changes instead of only reporting them")
+	flag.StringVar(&cfg.Color, "color", colorAuto, "color removed and added text in title diffs: auto (only on a terminal, unless NO_COLOR is set), always or never")
+	flag.BoolVar(&cfg.DryRun, "dry-run", false, "resolve manufacturers without updating the collection")
+	flag.StringVar(&cfg.Output, "output", "", "stream each processed aircraft to stdout in the given format (ndjson)")
+	flag.BoolVar(&cfg.Interactive, "interactive", false, "prompt for the manufacturer when a title matches several")
+	flag.BoolVar(&cfg.Stats, "stats", false, "include memory usage in the summary")
+	flag.BoolVar(&cfg.LengthHistogram, "length-histogram", false, "include a histogram of title lengths before and after stripping in the summary")
+	flag.IntVar(&cfg.MaxErrorDetails, "max-error-details", 100, "number of errors to report in full, the rest are only counted")
+	flag.StringVar(&cfg.SummaryJSON, "summary-json", "", "also write the run summary to this file as JSON")
+	flag.StringVar(&cfg.ReportHTML, "report-html", "", "with -dry-run, write the proposed changes to this file as an HTML page")
+	flag.Func("strip-prefixes", "comma-separated noise words to remove from the start of titles (e.g. The,New,Ex-)", func(value string) error {
+		cfg.StripPrefixes = splitList(value)
+		return nil
+	})
+	flag.BoolVar(&cfg.CanonicalTitle, "canonical-title", false, "write titles as the canonical manufacturer name followed by the stripped title")
+	flag.IntVar(&cfg.MaxTitleLength, "max-title-length", 0, "only look for manufacturers in the first this many bytes of titles, and cut longer stripped titles at a word boundary (0 for no limit)")
+	flag.BoolVar(&cfg.Phonetic, "phonetic", false, "as a last resort, match manufacturer names by how they sound (low confidence)")
+	flag.BoolVar(&cfg.StripParentheticals, "strip-parentheticals", false, "remove parenthetical clauses from titles, keeping them in the titleParentheticals field")
+	flag.Func("match-rules", "comma-separated match methods to use, tried in this order, the first to match wins: "+strings.Join(matchRules, ", "), func(value string) error {
+		cfg.MatchRules = splitList(value)
+		return nil
+	})
+	flag.StringVar(&cfg.TitleRegex, "title-regex", "", "only process aircraft whose title matches this regular expression")
+	flag.BoolVar(&cfg.OnlyEmpty, "only-empty-manufacturer", false, "only fetch aircraft with no manufacturer yet (missing, null or empty), filtering in the mongo query")
+	flag.BoolVar(&cfg.ServerSideFilter, "server-side-filter", false, "apply -title-regex in the mongo query (which uses PCRE syntax) rather than after fetching")
+	flag.StringVar(&cfg.MatchMode, "match-mode", modeAnywhere, "where manufacturers are matched in titles: anywhere, prefix or suffix")
+	cfg.Connectors = []string{"by", "from"}
+	flag.Func("connectors", "comma-separated words removed before a trailing manufacturer in suffix mode (default by,from)", func(value string) error {
+		cfg.Connectors = splitList(value)
+		return nil
+	})
+	flag.Func("compare-rules", "two colon-separated lists of -match-rules (e.g. exact,word:exact,word,phonetic); print the aircraft they resolve differently instead of writing", func(value string) error {
+		cfg.CompareRules = nil
+		for _, spec := range strings.Split(value, ":") {
+			cfg.CompareRules = append(cfg.CompareRules, splitList(spec))
+		}
+		return nil
+	})
+	flag.BoolVar(&cfg.Discover, "discover", false, "list the most common leading words of unmatched titles instead of writing")
+	flag.IntVar(&cfg.DiscoverTop, "discover-top", 20, "number of leading words -discover lists")
+	flag.StringVar(&cfg.Baseline, "baseline", "", "print only aircraft whose result differs from this earlier -output=ndjson export, without writing")
+	flag.StringVar(&cfg.CompareCollection, "compare-collection", "", "compare results against this reference collection instead of writing")
+	flag.BoolVar(&cfg.VerifyRefs, "verify-refs", false, "list aircraft whose manufacturer isn't in the manufacturers file instead of writing")
+	flag.BoolVar(&cfg.CollisionReport, "collision-report", false, "list manufacturers with confusable names and how many aircraft matched both, instead of writing")
+	flag.IntVar(&cfg.CollisionDistance, "collision-distance", 2, "names at most this many edits apart count as confusable for -collision-report")
+	flag.StringVar(&cfg.BSONDump, "bson-dump", "", "write resolved aircraft to this file as extended JSON lines for mongoimport, or raw BSON for mongorestore if it ends in .bson; - for stdout")
+	flag.IntVar(&cfg.Probe, "probe", 0, "print how this many randomly sampled aircraft would be resolved, instead of writing")
+	flag.StringVar(&cfg.UpsertCollection, "upsert-collection", "", "upsert results into this collection keyed by -upsert-key instead of updating aircraft")
+	flag.StringVar(&cfg.UpsertKey, "upsert-key", defaultUpsertKey, "aircraft field that keys documents in -upsert-collection")
+	flag.StringVar(&cfg.Manufacturers, "manufacturers", "", "manufacturers file, optionally gzipped; defaults to manufacturers.json, then manufacturers.json.gz, then the built-in list with a warning")
+	flag.BoolVar(&cfg.UseEmbedded, "use-embedded", false, "use the starter list of manufacturers built into the binary instead of a file; its IDs are slugs like boeing, so check they match the database before writing")
+	flag.BoolVar(&cfg.LenientManufacturers, "lenient-manufacturers", false, "skip manufacturers without an id or name, or with a repeated id, instead of failing")
+	flag.StringVar(&cfg.Overrides, "overrides", "", "JSON file mapping aircraft IDs to manufacturer IDs, applied before and instead of title matching")
+	flag.StringVar(&cfg.ICAOManufacturers, "icao-manufacturers", "", "JSON file mapping ICAO type designators to manufacturer IDs; matches it disagrees with are reported, and skipped with -strict")
+	flag.StringVar(&cfg.ManufacturerArrayField, "manufacturer-array-field", "", "add every manufacturer found in the title to this array field instead of setting manufacturer")
+	flag.BoolVar(&cfg.ManufacturerAsObjectID, "manufacturer-as-objectid", false, "write the manufacturer as an ObjectID reference instead of a string")
+	flag.StringVar(&cfg.AuditCollection, "audit-collection", "", "record every title and manufacturer change in this collection")
+	flag.StringVar(&cfg.AuditFile, "audit-file", "", "append every title and manufacturer change to this file as JSON lines")
+	flag.StringVar(&cfg.RevertRun, "revert-run", "", "undo the changes the run with this ID recorded in -audit-collection")
+	flag.BoolVar(&cfg.Optimistic, "optimistic", false, "only update aircraft whose title is still the one read, skipping those edited concurrently")
+	flag.IntVar(&cfg.BatchSize, "batch-size", 0, "send updates as bulk writes of up to this many, shrinking the batches while the cluster is slow (0 to update one at a time)")
+	flag.DurationVar(&cfg.BatchSlow, "batch-slow", defaultBatchSlow, "bulk writes taking longer than this halve -batch-size for the next ones")
+	flag.IntVar(&cfg.MaxUpdates, "max-updates", 0, "fail the run rather than update more than this many aircraft (0 for no limit)")
+	flag.StringVar(&cfg.MaxUpdatesMode, "max-updates-mode", maxUpdatesAbortBefore, "abort-before: write nothing if the run would go over -max-updates; stop-at: write up to the limit, then stop")
+	flag.IntVar(&cfg.GenerateFixtures, "generate-fixtures", 0, "generate this many synthetic aircraft from the manufacturers list into -fixtures-file or -fixtures-collection, then exit")
+	flag.StringVar(&cfg.FixturesFile, "fixtures-file", "", "JSON file -generate-fixtures writes to")
+	flag.StringVar(&cfg.FixturesCollection, "fixtures-collection", "", "collection -generate-fixtures inserts into")
+	flag.Float64Var(&cfg.FixturesNoise, "fixtures-noise", 0.2, "share of generated titles given a typo or odd casing")
+	flag.Uint64Var(&cfg.FixturesSeed, "fixtures-seed", 1, "seed for -generate-fixtures, the same seed gives the same fixtures")
+	flag.BoolVar(&cfg.NormalizeOnly, "normalize-only", false, "only clean up titles (whitespace, -strip-prefixes, -max-title-length, -strip-parentheticals, -clean-punctuation, -title-case) and write them back, leaving manufacturers alone")
+	flag.StringVar(&cfg.TitleCase, "title-case", caseKeep, "with -normalize-only, how titles are recased: keep, title (capitalize every word), upper or lower")
+	flag.BoolVar(&cfg.CleanPunctuation, "clean-punctuation", false, "with -normalize-only, drop spaces before and repeats of , ; : ! ? and separators left at either end of titles")
+	flag.BoolVar(&cfg.Incremental, "incremental", false, "only process aircraft with an _id past the last incremental run's, and record the new checkpoint in parser_state")
+	flag.BoolVar(&cfg.Watch, "watch", false, "instead of a one-off pass, resolve aircraft as they are inserted until interrupted")
+	flag.BoolVar(&cfg.WatchTitleUpdates, "watch-updates", false, "with -watch, also resolve aircraft whose title is updated")
+	flag.StringVar(&cfg.WatchResumeFile, "watch-resume-file", defaultWatchResumeFile, "file keeping the -watch resume token between runs")
+	flag.Parse()
+
+	if cfg.needsDatabase() {
+		uri, err := mongoURI()
+		if err != nil {
+			return cfg, &ConfigError{Err: err}
+		}
+		cfg.MongoURI, cfg.Database = uri, os.Getenv("MONGO_DB")
+	}
+
+	return cfg, cfg.prepare()
+}
+
+// Flag defaults that a Config built by hand gets too, see prepare
+const (
+	defaultReadPref        = "primary"
+	defaultUpsertKey       = "icaoCode"
+	defaultBatchSlow       = 2 * time.Second
+	defaultWatchResumeFile = ".watch-resume-token"
+)
+
+// prepare validates the config and fills in the fields derived from it.
+// Run calls it, so a Config built by hand is checked like one from flags;
+// settings left empty that have no valid zero value get the flag default.
+func (cfg *Config) prepare() error {
+	cfg.MatchMode = cmp.Or(cfg.MatchMode, modeAnywhere)
+	cfg.ReadPref = cmp.Or(cfg.ReadPref, defaultReadPref)
+	cfg.UpsertKey = cmp.Or(cfg.UpsertKey, defaultUpsertKey)
+	cfg.BatchSlow = cmp.Or(cfg.BatchSlow, defaultBatchSlow)
+	cfg.MaxUpdatesMode = cmp.Or(cfg.MaxUpdatesMode, maxUpdatesAbortBefore)
+	cfg.Color = cmp.Or(cfg.Color, colorAuto)
+	cfg.TitleCase = cmp.Or(cfg.TitleCase, caseKeep)
+	cfg.WatchResumeFile = cmp.Or(cfg.WatchResumeFile, defaultWatchResumeFile)
+	cfg.Stdin = cmp.Or(cfg.Stdin, io.Reader(os.Stdin))
+	cfg.Stdout = cmp.Or(cfg.Stdout, io.Writer(os.Stdout))
+	cfg.Stderr = cmp.Or(cfg.Stderr, io.Writer(os.Stderr))
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	var err error
+	cfg.writeConcern, err = parseWriteConcern(cfg.WriteConcern)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+	cfg.titleRegex = nil
+	if cfg.TitleRegex != "" {
+		cfg.titleRegex, err = regexp.Compile(cfg.TitleRegex)
+		if err != nil {
+			return configErrorf("invalid -title-regex: %w", err)
+		}
+	}
+	if !cfg.needsDatabase() {
+		return nil
+	}
+	if cfg.MongoURI == "" {
+		return configErrorf("no connection string: neither MONGODB_URL nor MONGODB_URL_FILE is set")
+	}
+	if cfg.Database == "" {
+		return configErrorf("no database: MONGO_DB is not set")
+	}
+
+	return nil
+}
+
+// split a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// validateRules checks a list of match rules, as -match-rules takes them
+func validateRules(rules []string) error {
+	for i, rule := range rules {
+		if !slices.Contains(matchRules, rule) {
+			return configErrorf("unknown match rule %q, expected one of %s", rule, strings.Join(matchRules, ", "))
+		}
+		if slices.Contains(rules[:i], rule) {
+			return configErrorf("match rule %q is listed twice", rule)
+		}
+	}
+
+	return nil
+}
+
+const outputNDJSON = "ndjson"
+
+func (cfg Config) validate() error {
+	if !slices.Contains(matchModes, cfg.MatchMode) {
+		return configErrorf("unknown match mode %q", cfg.MatchMode)
+	}
+	if err := validateRules(cfg.MatchRules); err != nil {
+		return err
+	}
+	if cfg.CompareRules != nil {
+		if len(cfg.CompareRules) != 2 || len(cfg.CompareRules[0]) == 0 || len(cfg.CompareRules[1]) == 0 {
+			return configErrorf("-compare-rules takes two rule lists separated by a colon, e.g. exact,word:exact,word,phonetic")
+		}
+		for _, rules := range cfg.CompareRules {
+			if err := validateRules(rules); err != nil {
+				return err
+			}
+		}
+		if len(cfg.MatchRules) > 0 {
+			return configErrorf("-compare-rules and -match-rules both choose the match rules")
+		}
+	}
+	if cfg.Phonetic && len(cfg.MatchRules) > 0 && !slices.Contains(cfg.MatchRules, methodPhonetic) {
+		return configErrorf("-phonetic has no effect unless -match-rules lists phonetic")
+	}
+	if cfg.MaxTitleLength < 0 {
+		return configErrorf("-max-title-length can't be negative")
+	}
+	if cfg.ConnectRetries < 0 {
+		return configErrorf("-connect-retries can't be negative")
+	}
+	if _, ok := readPreferences[cfg.ReadPref]; !ok {
+		return configErrorf("unknown read preference %q", cfg.ReadPref)
+	}
+	if !slices.Contains(maxUpdatesModes, cfg.MaxUpdatesMode) {
+		return configErrorf("unknown -max-updates-mode %q, expected one of %s", cfg.MaxUpdatesMode, strings.Join(maxUpdatesModes, ", "))
+	}
+	if cfg.BatchSize < 0 {
+		return configErrorf("-batch-size must not be negative")
+	}
+	if cfg.Optimistic && cfg.unacknowledged() {
+		return configErrorf("-optimistic needs to know whether each update matched, which -write-concern=0 never reports")
+	}
+	if cfg.Optimistic && (cfg.BatchSize > 0 || cfg.UpsertCollection != "") {
+		return configErrorf("-optimistic only applies to single in-place updates and can't be combined with -batch-size or -upsert-collection")
+	}
+	if cfg.BatchSlow <= 0 {
+		return configErrorf("-batch-slow must be positive")
+	}
+	if cfg.BatchSize > 0 && (cfg.Watch || cfg.UpsertCollection != "" || cfg.AuditCollection != "") {
+		return configErrorf("-batch-size only batches in-place updates and can't be combined with -watch, -upsert-collection or -audit-collection")
+	}
+	if cfg.MaxUpdates < 0 {
+		return configErrorf("-max-updates must not be negative")
+	}
+	if cfg.MaxUpdates > 0 && cfg.MaxUpdatesMode == maxUpdatesAbortBefore && (cfg.Watch || cfg.Interactive) {
+		return configErrorf("-max-updates-mode=%s needs to know every update up front, use %s with -watch and -interactive", maxUpdatesAbortBefore, maxUpdatesStopAt)
+	}
+	if cfg.UseEmbedded && cfg.Manufacturers != "" {
+		return configErrorf("-use-embedded and -manufacturers both choose the manufacturers list")
+	}
+	if !slices.Contains(colorModes, cfg.Color) {
+		return configErrorf("unknown -color %q, expected one of %s", cfg.Color, strings.Join(colorModes, ", "))
+	}
+	if cfg.Output != "" && cfg.Output != outputNDJSON {
+		return configErrorf("unknown output format %q", cfg.Output)
+	}
+	if cfg.UpsertCollection != "" {
+		if _, err := keyValue(Aircraft{}, cfg.UpsertKey); errors.Is(err, errNoSuchField) {
+			return configErrorf("unknown -upsert-key %q", cfg.UpsertKey)
+		}
+	}
+	if cfg.ReportHTML != "" && (!cfg.DryRun || cfg.mode() != modeUpdate) {
+		return configErrorf("-report-html needs -dry-run and can't be combined with other modes")
+	}
+	if cfg.AuditCollection != "" && cfg.UpsertCollection != "" {
+		return configErrorf("-audit-collection only records in-place updates and can't be combined with -upsert-collection")
+	}
+	if cfg.AuditCollection != "" && cfg.ManufacturerArrayField != "" {
+		return configErrorf("-audit-collection records the scalar manufacturer and can't be combined with -manufacturer-array-field")
+	}
+	if cfg.AuditFile != "" && (cfg.UpsertCollection != "" || cfg.ManufacturerArrayField != "" || cfg.BatchSize > 0) {
+		return configErrorf("-audit-file records single in-place updates of the scalar manufacturer and can't be combined with -upsert-collection, -manufacturer-array-field or -batch-size")
+	}
+	if cfg.RevertRun != "" && cfg.AuditCollection == "" {
+		return configErrorf("-revert-run needs the -audit-collection the run was recorded in")
+	}
+	if cfg.RevertRun != "" && (cfg.Watch || cfg.readOnly()) {
+		return configErrorf("-revert-run can't be combined with -watch, -dry-run, -discover, -baseline, -compare-collection, -verify-refs, -collision-report, -probe or -compare-rules")
+	}
+	if cfg.BSONDump != "" && cfg.mode() != modeUpdate && cfg.mode() != modeWatch {
+		return configErrorf("-bson-dump only applies when updating or watching aircraft")
+	}
+	if cfg.BSONDump == "-" && cfg.Output != "" {
+		return configErrorf("-bson-dump=- and -output both write to stdout")
+	}
+	if cfg.OpTimeout < 0 {
+		return configErrorf("-op-timeout must not be negative")
+	}
+	if cfg.Probe < 0 {
+		return configErrorf("-probe must not be negative")
+	}
+	if cfg.CollisionDistance < 0 {
+		return configErrorf("-collision-distance must not be negative")
+	}
+	if cfg.GenerateFixtures < 0 {
+		return configErrorf("-generate-fixtures must not be negative")
+	}
+	if cfg.GenerateFixtures > 0 && (cfg.FixturesFile == "") == (cfg.FixturesCollection == "") {
+		return configErrorf("-generate-fixtures needs exactly one of -fixtures-file and -fixtures-collection")
+	}
+	if cfg.FixturesCollection == "aircraft" {
+		return configErrorf("-fixtures-collection must not be the real aircraft collection")
+	}
+	if cfg.FixturesNoise < 0 || cfg.FixturesNoise > 1 {
+		return configErrorf("-fixtures-noise must be between 0 and 1")
+	}
+	if cfg.NormalizeOnly && (cfg.Watch || cfg.Interactive || cfg.UpsertCollection != "" || cfg.AuditCollection != "" || cfg.RevertRun != "" || (cfg.readOnly() && !cfg.DryRun)) {
+		return configErrorf("-normalize-only updates titles in place and can only be combined with -dry-run among the modes")
+	}
+	if cfg.NormalizeOnly && cfg.AuditFile != "" {
+		return configErrorf("-audit-file records manufacturer changes, which -normalize-only doesn't make")
+	}
+	if cfg.NormalizeOnly && (cfg.MaxUpdates > 0 || cfg.Incremental || cfg.BatchSize > 0 || cfg.Output != "") {
+		return configErrorf("-normalize-only writes titles one at a time and can't be combined with -max-updates, -incremental, -batch-size or -output")
+	}
+	if !slices.Contains(caseModes, cfg.TitleCase) {
+		return configErrorf("unknown -title-case %q, expected one of %s", cfg.TitleCase, strings.Join(caseModes, ", "))
+	}
+	if !cfg.NormalizeOnly && (cfg.TitleCase != caseKeep || cfg.CleanPunctuation) {
+		return configErrorf("-title-case and -clean-punctuation only apply with -normalize-only")
+	}
+	if cfg.OnlyEmpty && cfg.Watch {
+		return configErrorf("-only-empty-manufacturer filters the initial fetch, which -watch doesn't do")
+	}
+	if cfg.Incremental && cfg.Watch {
+		return configErrorf("-incremental can't be combined with -watch, which keeps its own resume token")
+	}
+	if cfg.Watch && cfg.readOnly() {
+		return configErrorf("-watch writes as it goes and can't be combined with read-only modes")
+	}
+	if cfg.Interactive && cfg.CompareCollection != "" {
+		return configErrorf("-interactive can't be combined with -compare-collection")
+	}
+
+	return nil
+}
+
+// aircraftFilter is the query selecting the aircraft to process
+func (cfg Config) aircraftFilter() bson.M {
+	filter := bson.M{}
+	if cfg.TitleRegex != "" && cfg.ServerSideFilter {
+		filter["title"] = bson.M{"$regex": cfg.TitleRegex}
+	}
+	if cfg.OnlyEmpty {
+		// null also matches documents without the field
+		filter["manufacturer"] = bson.M{"$in": bson.A{nil, ""}}
+	}
+
+	return filter
+}
+
+// How -max-updates stops a run
+const (
+	// Count the updates first and write nothing if there are too many
+	maxUpdatesAbortBefore = "abort-before"
+	// Write until the limit is reached, then fail
+	maxUpdatesStopAt = "stop-at"
+)
+
+var maxUpdatesModes = []string{maxUpdatesAbortBefore, maxUpdatesStopAt}
+
+var readPreferences = map[string]*readpref.ReadPref{
+	"primary":   readpref.Primary(),
+	"secondary": readpref.Secondary(),
+	"nearest":   readpref.Nearest(),
+}
+
+// What a run does with the aircraft
+const (
+	modeUpdate    = "update"
+	modeWatch     = "watch"
+	modeDiscover  = "discover"
+	modeBaseline  = "baseline"
+	modeCompare   = "compare"
+	modeRevert    = "revert"
+	modeVerify    = "verify-refs"
+	modeNormalize = "normalize"
+	modeFixtures  = "fixtures"
+	modeCollision = "collision-report"
+	modeProbe     = "probe"
+	modeRules     = "compare-rules"
+)
+
+// needsDatabase reports whether the run connects at all. Fixtures written to
+// a file only need the manufacturers, for contributors without a database.
+func (cfg Config) needsDatabase() bool {
+	return cfg.GenerateFixtures == 0 || cfg.FixturesFile == ""
+}
+
+// streams reports whether the run processes aircraft as they're read rather
+// than fetching them all first. Only checking -max-updates up front and
+// recording the -incremental checkpoint need them all.
+func (cfg Config) streams() bool {
+	abortBefore := cfg.MaxUpdates > 0 && cfg.MaxUpdatesMode == maxUpdatesAbortBefore && !cfg.DryRun
+
+	return cfg.mode() == modeUpdate && !cfg.Incremental && !abortBefore
+}
+
+func (cfg Config) mode() string {
+	switch {
+	case cfg.GenerateFixtures > 0:
+		return modeFixtures
+	case cfg.RevertRun != "":
+		return modeRevert
+	case cfg.NormalizeOnly:
+		return modeNormalize
+	case cfg.Watch:
+		return modeWatch
+	case cfg.Discover:
+		return modeDiscover
+	case cfg.Baseline != "":
+		return modeBaseline
+	case cfg.CompareCollection != "":
+		return modeCompare
+	case cfg.VerifyRefs:
+		return modeVerify
+	case cfg.CollisionReport:
+		return modeCollision
+	case cfg.Probe > 0:
+		return modeProbe
+	case cfg.CompareRules != nil:
+		return modeRules
+	}
+
+	return modeUpdate
+}
+
+// readOnly reports whether the run only reads from the database
+func (cfg Config) readOnly() bool {
+	return cfg.DryRun || cfg.Discover || cfg.Baseline != "" || cfg.CompareCollection != "" || cfg.VerifyRefs || cfg.CollisionReport || cfg.Probe > 0 || cfg.CompareRules != nil
+}
+
+// readPreference applies -read-preference to read-only runs. Runs that write
+// always read from the primary so they don't act on stale documents.
+func (cfg Config) readPreference() *readpref.ReadPref {
+	if !cfg.readOnly() {
+		return readpref.Primary()
+	}
+
+	return readPreferences[cfg.ReadPref]
+}
+
+// target is the collection results are written to
+func (cfg Config) target() string {
+	if cfg.UpsertCollection != "" {
+		return cfg.UpsertCollection
+	}
+
+	return "aircraft"
+}
+
+// upsertKey is the field results are keyed by, empty when updating in place
+func (cfg Config) upsertKey() string {
+	if cfg.UpsertCollection == "" {
+		return ""
+	}
+
+	return cfg.UpsertKey
+}
+
+// keepRaw reports whether aircraft documents are kept whole as they're read,
+// for the -bson-dump and audits that need every field and type
+func (cfg Config) keepRaw() bool {
+	return cfg.BSONDump != "" || cfg.AuditCollection != "" || cfg.AuditFile != ""
+}
+
+// messages is where progress and errors go, kept off stdout while it
+// carries streamed output
+func (cfg Config) messages() io.Writer {
+	if cfg.Output != "" || cfg.BSONDump == "-" {
+		return cmp.Or(cfg.Stderr, io.Writer(os.Stderr))
+	}
+
+	return cmp.Or(cfg.Stdout, io.Writer(os.Stdout))
+}
+
+// unacknowledged reports whether -write-concern asks for writes nobody
+// waits on
+func (cfg Config) unacknowledged() bool {
+	w, err := strconv.Atoi(cfg.WriteConcern)
+
+	return err == nil && w == 0
+}
+
+// parse the -write-concern value, nil means use the cluster default
+func parseWriteConcern(value string) (*writeconcern.WriteConcern, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if value == "majority" {
+		return writeconcern.Majority(), nil
+	}
+	w, err := strconv.Atoi(value)
+	if err != nil || w < 0 {
+		return nil, fmt.Errorf("invalid write concern %q", value)
+	}
+
+	return &writeconcern.WriteConcern{W: w}, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrepareHandBuiltConfig(t *testing.T) {
+	// nothing comes from the environment
+	t.Setenv("MONGODB_URL", "")
+	t.Setenv("MONGODB_URL_FILE", "")
+	t.Setenv("MONGO_DB", "")
+
+	cfg := Config{MongoURI: "mongodb://localhost", Database: "test", TitleRegex: "737", WriteConcern: "majority"}
+	if err := cfg.prepare(); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if cfg.titleRegex == nil || !cfg.titleRegex.MatchString("Boeing 737") {
+		t.Errorf("title regex not compiled: %v", cfg.titleRegex)
+	}
+	if cfg.writeConcern == nil || cfg.writeConcern.W != "majority" {
+		t.Errorf("write concern = %+v, want majority", cfg.writeConcern)
+	}
+	if cfg.Stdin == nil || cfg.Stdout == nil || cfg.Stderr == nil {
+		t.Errorf("streams left unset: %v %v %v", cfg.Stdin, cfg.Stdout, cfg.Stderr)
+	}
+}
+
+func TestPrepareNeedsConnection(t *testing.T) {
+	tests := map[string]Config{
+		"no connection string": {Database: "test"},
+		"no database":          {MongoURI: "mongodb://localhost"},
+	}
+	for name, cfg := range tests {
+		var configErr *ConfigError
+		if err := cfg.prepare(); !errors.As(err, &configErr) {
+			t.Errorf("%s: prepare() = %v, want a ConfigError", name, err)
+		}
+	}
+}
+
+func TestMessagesUsesConfigStreams(t *testing.T) {
+	var stdout, stderr strings.Builder
+	cfg := Config{Stdout: &stdout, Stderr: &stderr}
+	fmt.Fprint(cfg.messages(), "plain")
+	cfg.Output = outputNDJSON
+	fmt.Fprint(cfg.messages(), "streaming")
+	if stdout.String() != "plain" || stderr.String() != "streaming" {
+		t.Errorf("stdout got %q, stderr got %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestPrepareRejects(t *testing.T) {
+	tests := map[string]Config{
+		"batch with audit":       {BatchSize: 10, AuditCollection: "audit"},
+		"bad title regex":        {TitleRegex: "("},
+		"bad write concern":      {WriteConcern: "most"},
+		"optimistic without ack": {Optimistic: true, WriteConcern: "0"},
+		"normalize with audit":   {NormalizeOnly: true, AuditFile: "audit.jsonl"},
+		"normalize with limit":   {NormalizeOnly: true, MaxUpdates: 10},
+		"normalize incremental":  {NormalizeOnly: true, Incremental: true},
+		"normalize in batches":   {NormalizeOnly: true, BatchSize: 100},
+		"normalize with output":  {NormalizeOnly: true, Output: outputNDJSON},
+		"bad title case":         {NormalizeOnly: true, TitleCase: "shout"},
+		"title case alone":       {TitleCase: caseUpper},
+		"punctuation alone":      {CleanPunctuation: true},
+	}
+	for name, cfg := range tests {
+		// so it's the setting that's rejected, not the missing database
+		cfg.MongoURI, cfg.Database = "mongodb://localhost", "test"
+		var configErr *ConfigError
+		if err := cfg.prepare(); !errors.As(err, &configErr) {
+			t.Errorf("%s: prepare() = %v, want a ConfigError", name, err)
+		}
+	}
+}
+
+func TestStreams(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want bool
+	}{
+		{Config{}, true},
+		{Config{DryRun: true, Output: outputNDJSON}, true},
+		{Config{MaxUpdates: 5, MaxUpdatesMode: maxUpdatesStopAt}, true},
+		// a dry run never writes, so there's nothing to check up front
+		{Config{DryRun: true, MaxUpdates: 5, MaxUpdatesMode: maxUpdatesAbortBefore}, true},
+		{Config{MaxUpdates: 5, MaxUpdatesMode: maxUpdatesAbortBefore}, false},
+		{Config{Incremental: true}, false},
+		{Config{Discover: true}, false},
+		{Config{NormalizeOnly: true}, false},
+	}
+	for i, tt := range tests {
+		if got := tt.cfg.streams(); got != tt.want {
+			t.Errorf("%d: streams() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestPrepareNormalizeOnly(t *testing.T) {
+	cfg := Config{MongoURI: "mongodb://localhost", Database: "test", NormalizeOnly: true, DryRun: true, TitleCase: caseTitle, CleanPunctuation: true}
+	if err := cfg.prepare(); err != nil {
+		t.Errorf("prepare() = %v", err)
+	}
+}
+
+func TestRunFixturesFileWithoutDatabase(t *testing.T) {
+	t.Setenv("MONGODB_URL", "")
+	t.Setenv("MONGODB_URL_FILE", "")
+	t.Setenv("MONGO_DB", "")
+
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	report, err := Run(context.Background(), Config{GenerateFixtures: 3, FixturesFile: path, UseEmbedded: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil || report.Processed != 3 {
+		t.Errorf("generated %d fixtures, %v, want 3 in %s", report.Processed, err, path)
+	}
+
+	cfg := Config{GenerateFixtures: 3, FixturesCollection: "fixtures", UseEmbedded: true}
+	if err := cfg.prepare(); err == nil {
+		t.Error("fixtures inserted into a collection didn't need a database")
+	}
+}
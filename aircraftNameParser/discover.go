@@ -0,0 +1,71 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const discoverExamples = 3
+
+type tokenCount struct {
+	Token    string
+	Count    int
+	Examples []string
+}
+
+// discoverManufacturers tallies the first word of every title that has no
+// manufacturer yet and that the matcher can't resolve either, and prints the
+// most common ones. Frequent leading words are usually manufacturers missing
+// from the list.
+func discoverManufacturers(aircraft []Aircraft, matcher Matcher, top int, out io.Writer) {
+	counts := make(map[string]*tokenCount)
+	unmatched := 0
+	for _, a := range aircraft {
+		if a.Manufacturer != "" || matcher.resolve(a).Matched() {
+			continue
+		}
+		title := matcher.normalize(a.Title)
+		token, _, _ := strings.Cut(title, " ")
+		if token == "" {
+			continue
+		}
+		unmatched++
+		tc, ok := counts[token]
+		if !ok {
+			tc = &tokenCount{Token: token}
+			counts[token] = tc
+		}
+		tc.Count++
+		if len(tc.Examples) < discoverExamples {
+			tc.Examples = append(tc.Examples, title)
+		}
+	}
+
+	var tokens []*tokenCount
+	for _, tc := range counts {
+		tokens = append(tokens, tc)
+	}
+	slices.SortFunc(tokens, func(a, b *tokenCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Token, b.Token))
+	})
+	if top > 0 && len(tokens) > top {
+		tokens = tokens[:top]
+	}
+
+	fmt.Fprintf(out, "%d unmatched titles, most common leading words:\n", unmatched)
+	for _, tc := range tokens {
+		fmt.Fprintf(out, "%6d  %s  e.g. %s\n", tc.Count, tc.Token, strings.Join(quoteAll(tc.Examples), ", "))
+	}
+}
+
+func quoteAll(items []string) []string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+
+	return quoted
+}
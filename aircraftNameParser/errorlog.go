@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Stages of the run an error can come from
+const (
+	stageDecode = "decode"
+	stageOutput = "output"
+	stageUpdate = "update"
+)
+
+var stages = []string{stageDecode, stageOutput, stageUpdate}
+
+type RunError struct {
+	AircraftID string `json:"aircraftId"`
+	Stage      string `json:"stage"`
+	Err        string `json:"error"`
+}
+
+// ErrorLog collects the errors of a run so they can be reported together.
+// Every error is counted but only the first Max are kept in full, so a run
+// that fails on every document doesn't hold all of them in memory.
+type ErrorLog struct {
+	Max     int            `json:"-"`
+	Counts  map[string]int `json:"counts"`
+	Details []RunError     `json:"details"`
+}
+
+func newErrorLog(max int) *ErrorLog {
+	return &ErrorLog{Max: max, Counts: make(map[string]int)}
+}
+
+func (l *ErrorLog) add(aircraftID, stage string, err error) {
+	l.Counts[stage]++
+	if len(l.Details) < l.Max {
+		l.Details = append(l.Details, RunError{AircraftID: aircraftID, Stage: stage, Err: err.Error()})
+	}
+}
+
+func (l *ErrorLog) total() int {
+	total := 0
+	for _, n := range l.Counts {
+		total += n
+	}
+
+	return total
+}
+
+func (l *ErrorLog) Print(w io.Writer) {
+	if l.total() == 0 {
+		return
+	}
+	fmt.Fprintf(w, "errors: %d\n", l.total())
+	for _, stage := range stages {
+		if l.Counts[stage] == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %s: %d\n", stage, l.Counts[stage])
+		for _, e := range l.Details {
+			if e.Stage == stage {
+				fmt.Fprintf(w, "    %s: %s\n", e.AircraftID, e.Err)
+			}
+		}
+	}
+	if omitted := l.total() - len(l.Details); omitted > 0 {
+		fmt.Fprintf(w, "  (%d more not shown)\n", omitted)
+	}
+}
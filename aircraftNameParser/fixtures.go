@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fixture is a synthetic aircraft along with the manufacturer it was made
+// from, so matching rules can be checked against it
+type fixture struct {
+	ID                   string `json:"_id" bson:"_id"`
+	Title                string `json:"title" bson:"title"`
+	Icao                 string `json:"icaoCode,omitempty" bson:"icaoCode,omitempty"`
+	ExpectedManufacturer string `json:"expectedManufacturer" bson:"expectedManufacturer"`
+}
+
+// generateFixtures makes n aircraft by naming a random manufacturer next to
+// a made-up model, in the title orders seen in real data. With probability
+// noise a title also gets a typo or odd casing. The same seed always gives
+// the same fixtures.
+func generateFixtures(manufacturers []Manufacturer, n int, noise float64, seed uint64) []fixture {
+	r := rand.New(rand.NewPCG(seed, seed))
+	fixtures := make([]fixture, 0, n)
+	for i := range n {
+		mf := manufacturers[r.IntN(len(manufacturers))]
+		model := fmt.Sprintf("%c%d", 'A'+r.IntN(26), 100+r.IntN(900))
+		if r.IntN(2) == 0 {
+			model = fmt.Sprintf("%d-%d00", 100+r.IntN(900), 1+r.IntN(9))
+		}
+		var title string
+		switch r.IntN(4) {
+		case 0, 1:
+			title = mf.Name + " " + model
+		case 2:
+			title = model + " by " + mf.Name
+		default:
+			title = "The " + mf.Name + " " + model
+		}
+		if r.Float64() < noise {
+			title = addNoise(r, title)
+		}
+		f := fixture{ID: fmt.Sprintf("fixture-%06d", i+1), Title: title, ExpectedManufacturer: mf.ID}
+		if r.IntN(3) == 0 {
+			code := strings.ReplaceAll(model, "-", "")
+			f.Icao = code[:min(4, len(code))]
+		}
+		fixtures = append(fixtures, f)
+	}
+
+	return fixtures
+}
+
+// addNoise swaps two neighbouring letters, drops one, or changes the case of
+// the whole title
+func addNoise(r *rand.Rand, title string) string {
+	b := []byte(title)
+	i := r.IntN(len(b))
+	switch r.IntN(4) {
+	case 0:
+		if i+1 < len(b) && unicode.IsLetter(rune(b[i])) && unicode.IsLetter(rune(b[i+1])) {
+			b[i], b[i+1] = b[i+1], b[i]
+		}
+		return string(b)
+	case 1:
+		if unicode.IsLetter(rune(b[i])) {
+			return string(b[:i]) + string(b[i+1:])
+		}
+		return title
+	case 2:
+		return strings.ToLower(title)
+	}
+
+	return strings.ToUpper(title)
+}
+
+func writeFixturesFile(path string, fixtures []fixture) error {
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func insertFixtures(ctx context.Context, collection *mongo.Collection, fixtures []fixture) error {
+	docs := make([]any, len(fixtures))
+	for i, f := range fixtures {
+		docs[i] = f
+	}
+	ctx, cancel := opContext(ctx)
+	defer cancel()
+	_, err := collection.InsertMany(ctx, docs)
+
+	return acknowledged(err)
+}
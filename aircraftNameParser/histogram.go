@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	histogramBucket  = 5  // Characters per bucket
+	histogramBuckets = 11 // Buckets after the empty one, the last open-ended
+)
+
+// LengthHistogram counts title lengths in characters. The first bucket is
+// empty titles only, then 1-5, 6-10 and so on, the last bucket taking every
+// longer title.
+type LengthHistogram []int
+
+func (h *LengthHistogram) add(title string) {
+	if *h == nil {
+		*h = make(LengthHistogram, histogramBuckets+1)
+	}
+	n := utf8.RuneCountInString(title)
+	bucket := 0
+	if n > 0 {
+		bucket = min((n-1)/histogramBucket+1, histogramBuckets)
+	}
+	(*h)[bucket]++
+}
+
+func bucketLabel(i int) string {
+	switch {
+	case i == 0:
+		return "0"
+	case i == histogramBuckets:
+		return fmt.Sprintf("%d+", (i-1)*histogramBucket+1)
+	}
+
+	return fmt.Sprintf("%d-%d", (i-1)*histogramBucket+1, i*histogramBucket)
+}
+
+// printHistograms prints the before and after counts side by side
+func printHistograms(w io.Writer, before, after LengthHistogram) {
+	fmt.Fprintln(w, "title lengths:  before    after")
+	for i := range before {
+		fmt.Fprintf(w, "%12s  %7d  %7d\n", bucketLabel(i), before[i], after[i])
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// loadICAOManufacturers reads a JSON object mapping ICAO type designators,
+// e.g. "B738", to the ID of the manufacturer that builds the type
+func loadICAOManufacturers(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, configErrorf("failed to parse ICAO manufacturers: %s: %w", path, err)
+	}
+	byCode := make(map[string]string, len(mapping))
+	for code, id := range mapping {
+		byCode[strings.ToUpper(strings.TrimSpace(code))] = id
+	}
+
+	return byCode, nil
+}
+
+// icaoDisagreement returns the manufacturer the aircraft's ICAO code says
+// built it, when that isn't the one its title matched. Overrides are taken
+// as they are.
+func (p *processor) icaoDisagreement(a Aircraft, res Resolution) (string, bool) {
+	if p.icaoManufacturers == nil || res.Method == methodOverride {
+		return "", false
+	}
+	expected, ok := p.icaoManufacturers[strings.ToUpper(strings.TrimSpace(a.Icao))]
+
+	return expected, ok && expected != res.Manufacturer
+}
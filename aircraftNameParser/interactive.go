@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// resolveInteractively resolves the aircraft like resolve, except that when
+// several manufacturers are found in its title the user picks one. An empty
+// answer leaves the aircraft unmatched.
+func (m Matcher) resolveInteractively(a Aircraft, in *bufio.Reader, out io.Writer) Resolution {
+	// a hand-assigned manufacturer is never up for discussion, and a clean
+	// title has nothing left to pick
+	if _, ok := m.Overrides[a.ID]; ok || m.alreadyClean(a) {
+		return m.resolve(a)
+	}
+	title := m.normalize(a.Title)
+	window, _ := m.truncate(title)
+	candidates := m.findCandidates(window)
+	if len(candidates) < 2 {
+		return m.resolve(a)
+	}
+
+	if c, ok := promptCandidate(a, candidates, in, out); ok {
+		return m.resolveAs(a, title, c)
+	}
+
+	return unresolved(a, title)
+}
+
+func promptCandidate(a Aircraft, candidates []candidate, in *bufio.Reader, out io.Writer) (candidate, bool) {
+	fmt.Fprintf(out, "%s: %q matches several manufacturers\n", a.ID, a.Title)
+	for i, c := range candidates {
+		fmt.Fprintf(out, "  %d) %s (%s)\n", i+1, c.Manufacturer.Name, c.Manufacturer.ID)
+	}
+	for {
+		fmt.Fprint(out, "pick one, or press enter to skip: ")
+		line, err := in.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return candidate{}, false
+		}
+		n, convErr := strconv.Atoi(line)
+		if convErr == nil && n >= 1 && n <= len(candidates) {
+			return candidates[n-1], true
+		}
+		if err != nil {
+			// stdin is closed, so there is no one left to ask
+			return candidate{}, false
+		}
+		fmt.Fprintf(out, "%q is not one of the choices\n", line)
+	}
+}
@@ -1,27 +1,73 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 func main() {
 	loadDotEnv()
-	ctx := context.TODO()
-	mongoDB := connectToMongo(ctx)
-	aircrafts := getAircrafts(ctx, mongoDB)
-	manufacturers := loadManufacturers()
-	addManufacturer(ctx, mongoDB, aircrafts, manufacturers)
+	cfg, err := loadConfig()
+	if err != nil {
+		exitWithError(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	report, err := Run(ctx, cfg)
+	stop()
+	if err == nil || report.Processed > 0 {
+		report.Print(cfg.messages())
+	}
+	if cfg.SummaryJSON != "" {
+		// Written even for failed runs, and not writing it fails the run,
+		// since CI checks it rather than the exit code alone
+		if err := report.WriteJSON(cfg.SummaryJSON); err != nil {
+			log.Fatalf("failed to write summary: %v", err)
+		}
+	}
+	if cfg.ReportHTML != "" && err == nil {
+		if err := report.WriteHTML(cfg.ReportHTML); err != nil {
+			log.Fatalf("failed to write HTML report: %v", err)
+		}
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+	if report.Mismatches > 0 || report.Dangling > 0 {
+		os.Exit(1)
+	}
+}
+
+// exitWithError exits with code 2 for configuration errors, so automation can
+// tell them apart from runtime failures, which exit with 1
+func exitWithError(err error) {
+	var cfgErr *ConfigError
+	if errors.As(err, &cfgErr) {
+		fmt.Fprintln(os.Stderr, "configuration error:", err)
+		os.Exit(2)
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
 }
 
 func loadDotEnv() {
@@ -31,75 +77,314 @@ func loadDotEnv() {
 	}
 }
 
-func connectToMongo(ctx context.Context) *mongo.Database {
+// connectToMongo connects and pings the server, trying again with backoff up
+// to retries more times so a brief outage at startup doesn't abort the run
+func connectToMongo(ctx context.Context, uri, database string, retries int) (*mongo.Database, error) {
 	apiOptions := mongoOptions.ServerAPI(mongoOptions.ServerAPIVersion1)
-	clientOptions := mongoOptions.Client().ApplyURI(os.Getenv("MONGODB_URL")).SetServerAPIOptions(apiOptions)
+	clientOptions := mongoOptions.Client().ApplyURI(uri).SetServerAPIOptions(apiOptions)
+	// a URI that doesn't parse won't get any better by retrying
+	if err := clientOptions.Validate(); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		client, err := tryConnect(ctx, clientOptions)
+		if err == nil {
+			return client.Database(database), nil
+		}
+		if attempt >= retries {
+			return nil, err
+		}
+		log.Printf("connecting to mongo failed (attempt %d of %d), retrying in %s: %v", attempt+1, retries+1, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// mongoURI reads the connection string from the file MONGODB_URL_FILE names,
+// such as a mounted secret, or else from MONGODB_URL itself
+func mongoURI() (string, error) {
+	path := os.Getenv("MONGODB_URL_FILE")
+	if path == "" {
+		return os.Getenv("MONGODB_URL"), nil
+	}
+	if os.Getenv("MONGODB_URL") != "" {
+		log.Printf("both MONGODB_URL_FILE and MONGODB_URL are set, using %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("MONGODB_URL_FILE: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+func tryConnect(ctx context.Context, clientOptions *mongoOptions.ClientOptions) (*mongo.Client, error) {
 	ctxTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	client, err := mongo.Connect(ctxTimeout, clientOptions)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	err = client.Ping(ctxTimeout, nil)
+	if err != nil {
+		_ = client.Disconnect(ctx)
+		return nil, err
 	}
 
-	return client.Database(os.Getenv("MONGO_DB"))
+	return client, nil
 }
 
-func getAircrafts(ctx context.Context, db *mongo.Database) []Aircraft {
-	cursor, err := db.Collection("aircraft").Find(ctx, bson.D{{}})
-	if err != nil {
-		fmt.Println(err)
-	}
+// getAircrafts fetches the aircraft to process, with keepRaw holding on to
+// the whole documents too. Documents that fail to decode
+// are recorded in errs and skipped.
+func getAircrafts(ctx context.Context, db *mongo.Database, readPreference *readpref.ReadPref, filter bson.M, keepRaw bool, errs *ErrorLog) ([]Aircraft, error) {
 	var aircrafts []Aircraft
-	err = cursor.All(ctx, &aircrafts)
+	err := eachAircraft(ctx, db, readPreference, filter, keepRaw, errs, func(a Aircraft) bool {
+		aircrafts = append(aircrafts, a)
+		return true
+	})
+
+	return aircrafts, err
+}
+
+// eachAircraft hands the aircraft to process to fn as they're read, until fn
+// returns false, as getAircrafts does. They come in _id order: an index on a
+// field the run updates could otherwise hand back an aircraft it has already
+// written.
+func eachAircraft(ctx context.Context, db *mongo.Database, readPreference *readpref.ReadPref, filter bson.M, keepRaw bool, errs *ErrorLog, fn func(Aircraft) bool) error {
+	collection := db.Collection("aircraft", mongoOptions.Collection().SetReadPreference(readPreference))
+	opCtx, cancel := opContext(ctx)
+	cursor, err := collection.Find(opCtx, filter, mongoOptions.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	cancel()
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
+	defer cursor.Close(ctx)
 
-	return aircrafts
+	for nextDoc(ctx, cursor) {
+		var a Aircraft
+		if err := cursor.Decode(&a); err != nil {
+			id, _ := cursor.Current.Lookup("_id").StringValueOK()
+			errs.add(id, stageDecode, err)
+			continue
+		}
+		if keepRaw {
+			// the cursor reuses its buffer for the next document
+			a.raw = slices.Clone(cursor.Current)
+		}
+		if !fn(a) {
+			break
+		}
+	}
+
+	return cursor.Err()
 }
 
-// load from manufacturers.json
-func loadManufacturers() map[string]string {
-	// Open the file
-	file, err := os.Open("manufacturers.json")
+// The list built into the binary, for -use-embedded
+//
+//go:embed embedded/manufacturers.json
+var embeddedManufacturers []byte
+
+// load from path, manufacturers.json by default, or the built-in list
+func loadManufacturers(path string, useEmbedded, lenient bool) ([]Manufacturer, error) {
+	src, name, err := openManufacturers(path, useEmbedded)
 	if err != nil {
-		fmt.Println(err)
+		return nil, err
 	}
+	defer src.Close()
 
+	r, err := decompressed(src)
+	if err != nil {
+		return nil, configErrorf("failed to parse manufacturers: %s: %w", name, err)
+	}
 	var data []Manufacturer
-	err = json.NewDecoder(file).Decode(&data)
+	err = json.NewDecoder(r).Decode(&data)
 	if err != nil {
-		fmt.Println(err)
+		return nil, configErrorf("failed to parse manufacturers: %s: %w", name, err)
+	}
+	data, err = validateManufacturers(data, lenient)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range data {
+		if err := data[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// compile prepares a manufacturer read from the file for matching
+func (m *Manufacturer) compile() error {
+	// titles have their whitespace collapsed before matching, so names
+	// need the same to match as a run of words
+	m.Name = strings.Join(strings.Fields(m.Name), " ")
+	if m.Pattern != "" {
+		pattern, err := regexp.Compile(m.Pattern)
+		if err != nil {
+			return configErrorf("pattern for manufacturer %s: %w", m.ID, err)
+		}
+		m.pattern = pattern
+	}
+	m.excludes = nil
+	for _, exclude := range m.ExcludePatterns {
+		re, err := regexp.Compile(exclude)
+		if err != nil {
+			return configErrorf("exclude pattern for manufacturer %s: %w", m.ID, err)
+		}
+		m.excludes = append(m.excludes, re)
+	}
+
+	return nil
+}
+
+// validateManufacturers checks every entry has an ID and a name and that no
+// ID is used twice, since a blank ID would be written onto aircraft as their
+// manufacturer. Offenders are reported by their index in the file. When
+// lenient they are only logged and dropped, keeping the first of a repeated
+// ID.
+func validateManufacturers(manufacturers []Manufacturer, lenient bool) ([]Manufacturer, error) {
+	var problems []string
+	var valid []Manufacturer
+	seen := make(map[string]int)
+	for i, m := range manufacturers {
+		problem := ""
+		switch first, dup := seen[m.ID]; {
+		case strings.TrimSpace(m.ID) == "":
+			problem = fmt.Sprintf("entry %d (%q) has no id", i, m.Name)
+		case strings.TrimSpace(m.Name) == "":
+			problem = fmt.Sprintf("entry %d (%s) has no name", i, m.ID)
+		case dup:
+			problem = fmt.Sprintf("entry %d repeats id %s of entry %d", i, m.ID, first)
+		default:
+			seen[m.ID] = i
+			valid = append(valid, m)
+			continue
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) == 0 {
+		return manufacturers, nil
+	}
+	if !lenient {
+		return nil, configErrorf("invalid manufacturers: %s", strings.Join(problems, "; "))
+	}
+	for _, problem := range problems {
+		log.Printf("skipping manufacturer: %s", problem)
+	}
+
+	return valid, nil
+}
+
+// openManufacturers opens the manufacturers list and names it for errors.
+// Without a path it's manufacturers.json, falling back to the gzipped
+// export and then, with a warning, to the built-in list. Its IDs are slugs
+// that are unlikely to be the ones the database refers to, so the warning
+// says so.
+func openManufacturers(path string, useEmbedded bool) (io.ReadCloser, string, error) {
+	if useEmbedded {
+		return io.NopCloser(bytes.NewReader(embeddedManufacturers)), "built-in manufacturers", nil
+	}
+	if path != "" {
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", configErrorf("manufacturers file not found: %s", path)
+		}
+		if err != nil {
+			return nil, "", &ConfigError{Err: err}
+		}
+		return file, path, nil
+	}
+
+	file, err := os.Open("manufacturers.json")
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.Open("manufacturers.json.gz")
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("WARNING: neither manufacturers.json nor manufacturers.json.gz was found, using the built-in manufacturers. Their IDs are slugs like %q: check the database refers to them before writing, or pass -manufacturers", "boeing")
+		return io.NopCloser(bytes.NewReader(embeddedManufacturers)), "built-in manufacturers", nil
+	}
+	if err != nil {
+		return nil, "", &ConfigError{Err: err}
+	}
+
+	return file, file.Name(), nil
+}
+
+// decompressed unwraps r if it's gzipped, whatever the file is called, and
+// returns it as is otherwise
+func decompressed(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err != nil || !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return buffered, nil
 	}
 
-	manufacturerMap := make(map[string]string)
-	for _, manufacturer := range data {
-		manufacturerMap[manufacturer.Name] = manufacturer.ID
+	return gzip.NewReader(buffered)
+}
+
+func filterByTitle(aircraft []Aircraft, re *regexp.Regexp) []Aircraft {
+	var matching []Aircraft
+	for _, a := range aircraft {
+		if re.MatchString(a.Title) {
+			matching = append(matching, a)
+		}
+	}
+
+	return matching
+}
+
+// streamManufacturers is addManufacturer for aircraft read straight off the
+// cursor, so only one is held at a time however many the run goes through.
+// With titleRegex set, only aircraft whose title matches it are processed.
+func streamManufacturers(ctx context.Context, p *processor, db *mongo.Database, readPreference *readpref.ReadPref, filter bson.M, titleRegex *regexp.Regexp) error {
+	defer p.flush(ctx)
+	err := eachAircraft(ctx, db, readPreference, filter, p.cfg.keepRaw(), p.errs, func(a Aircraft) bool {
+		if titleRegex == nil || titleRegex.MatchString(a.Title) {
+			p.process(ctx, a)
+		}
+		return p.halted == nil
+	})
+	if p.halted != nil {
+		return p.halted
 	}
 
-	return manufacturerMap
+	return err
 }
 
-func addManufacturer(ctx context.Context, db *mongo.Database, aircraft []Aircraft, manufacturers map[string]string) {
+func addManufacturer(ctx context.Context, p *processor, aircraft []Aircraft) error {
+	defer p.flush(ctx)
 	for _, a := range aircraft {
-		for mTitle, mID := range manufacturers {
-			contains := strings.Contains(a.Title, mTitle)
-			if contains {
-				title := strings.ReplaceAll(a.Title, mTitle, "")
-				title = strings.TrimSpace(title)
-				set := bson.M{"$set": bson.M{"manufacturer": mID, "title": title}}
-				_, err := db.Collection("aircraft").UpdateOne(ctx, bson.M{"_id": a.ID}, set)
-				if err != nil {
-					fmt.Println(err)
-				}
-			}
+		p.process(ctx, a)
+		if p.halted != nil {
+			return p.halted
 		}
 	}
+
+	return nil
 }
 
 type Manufacturer struct {
-	ID   string `json:"id"`   // The ID of the aircraft
-	Name string `json:"name"` // The manufacturer of the aircraft
+	ID      string `json:"id"`                // The ID of the aircraft
+	Name    string `json:"name"`              // The manufacturer of the aircraft
+	Pattern string `json:"pattern,omitempty"` // Optional regexp matching titles, a "model" group captures the model
+	// Tokens that identify the manufacturer when they make up a whole
+	// parenthetical, e.g. "ABS" in "A320 (ABS)". The parenthetical is stripped.
+	Parentheticals []string `json:"parentheticals,omitempty"`
+	// Regexps for titles this manufacturer must not match, e.g. "Anti-Airbus".
+	// An exclude always wins, however the manufacturer was otherwise matched.
+	ExcludePatterns []string `json:"excludePatterns,omitempty"`
+
+	pattern  *regexp.Regexp
+	excludes []*regexp.Regexp
 }
 
 type Aircraft struct {
@@ -108,4 +393,6 @@ type Aircraft struct {
 	Icao         string `json:"icao" bson:"icaoCode"`             // The ICAO code of the aircraft
 	Iata         string `json:"iata" bson:"iataCode"`             // The IATA code of the aircraft
 	Title        string `json:"title" bson:"title"`               // The title of the aircraft
+
+	raw bson.Raw // The whole document as read, only kept when Config.keepRaw says so
 }
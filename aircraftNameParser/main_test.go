@@ -0,0 +1,124 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const manufacturersFixture = `[{"id": "airbus", "name": "Airbus"}, {"id": "boeing", "name": "Boeing"}]`
+
+// writeGzip writes a gzipped copy of contents to name in dir
+func writeGzip(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := gzip.NewWriter(file)
+	if _, err := zw.Write([]byte(contents)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadManufacturersNotFound(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	_, err := loadManufacturers("missing.json", false, false)
+	if want := "manufacturers file not found: missing.json"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("loadManufacturers(%q) = %v, want %q", "missing.json", err, want)
+	}
+}
+
+func TestLoadManufacturersFallsBackToEmbedded(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var logged strings.Builder
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	manufacturers, err := loadManufacturers("", false, false)
+	if err != nil || len(manufacturers) == 0 {
+		t.Fatalf("loadManufacturers without a file = %d manufacturers, %v, want the built-in list", len(manufacturers), err)
+	}
+	if !strings.Contains(logged.String(), "WARNING") {
+		t.Errorf("falling back didn't warn, logged %q", logged.String())
+	}
+}
+
+func TestLoadManufacturersEmbedded(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	manufacturers, err := loadManufacturers("", true, false)
+	if err != nil || len(manufacturers) == 0 {
+		t.Fatalf("loadManufacturers with -use-embedded = %d manufacturers, %v", len(manufacturers), err)
+	}
+}
+
+func TestLoadManufacturersGzipped(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.json")
+	if err := os.WriteFile(plain, []byte(manufacturersFixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		plain,
+		writeGzip(t, dir, "manufacturers.json.gz", manufacturersFixture),
+		// told apart by its contents, not its name
+		writeGzip(t, dir, "gzipped.json", manufacturersFixture),
+	} {
+		manufacturers, err := loadManufacturers(path, false, false)
+		if err != nil {
+			t.Errorf("loadManufacturers(%q): %v", path, err)
+			continue
+		}
+		if len(manufacturers) != 2 || manufacturers[0].ID != "airbus" || manufacturers[1].Name != "Boeing" {
+			t.Errorf("loadManufacturers(%q) = %+v", path, manufacturers)
+		}
+	}
+
+	// found without -manufacturers when there's no manufacturers.json
+	t.Chdir(dir)
+	if manufacturers, err := loadManufacturers("", false, false); err != nil || len(manufacturers) != 2 {
+		t.Errorf("loadManufacturers found %d manufacturers, %v, want manufacturers.json.gz read", len(manufacturers), err)
+	}
+}
+
+func TestLoadManufacturersCorruptGzip(t *testing.T) {
+	path := writeGzip(t, t.TempDir(), "manufacturers.json.gz", manufacturersFixture)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loadManufacturers(path, false, false)
+	if err == nil || !strings.Contains(err.Error(), "failed to parse manufacturers") {
+		t.Errorf("loadManufacturers of a truncated gzip = %v, want a parse error", err)
+	}
+}
+
+func TestDecompressed(t *testing.T) {
+	for _, contents := range []string{"", "[", manufacturersFixture} {
+		r, err := decompressed(strings.NewReader(contents))
+		if err != nil {
+			t.Errorf("decompressed(%q): %v", contents, err)
+			continue
+		}
+		if got, err := io.ReadAll(r); err != nil || string(got) != contents {
+			t.Errorf("decompressed(%q) read %q, %v, want it unchanged", contents, got, err)
+		}
+	}
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	methodExact = "exact"
+	methodRegex = "regex"
+	// The manufacturer's name as a whole word in any case, e.g. "boeing 737"
+	methodWord = "word"
+	// The manufacturer is a token in parentheses, e.g. "A320 (ABS)"
+	methodParenthetical = "parenthetical"
+	// The title has words that sound like the manufacturer's name. This is
+	// a low-confidence match, only tried when nothing else matched.
+	methodPhonetic = "phonetic"
+	// The manufacturer was assigned by -overrides rather than found
+	methodOverride = "override"
+)
+
+// matchRules are the methods -match-rules can enable. Without it each
+// manufacturer is tried by defaultRules in order, then phonetically if asked.
+var (
+	matchRules   = []string{methodExact, methodRegex, methodWord, methodParenthetical, methodPhonetic}
+	defaultRules = []string{methodRegex, methodExact, methodWord, methodParenthetical}
+)
+
+type Resolution struct {
+	ID            string `json:"id"`                  // The ID of the aircraft
+	OriginalTitle string `json:"originalTitle"`       // The title as it was read
+	Title         string `json:"title"`               // The title with the manufacturer stripped
+	Manufacturer  string `json:"manufacturer"`        // The matched manufacturer ID, empty when unmatched
+	Model         string `json:"model,omitempty"`     // The model captured by the manufacturer's pattern
+	Method        string `json:"method,omitempty"`    // How the manufacturer was matched
+	LostCode      string `json:"lostCode,omitempty"`  // ICAO/IATA code the strip removed from the title
+	Truncated     bool   `json:"truncated,omitempty"` // The title was cut down to the maximum length
+	// Parenthetical clauses removed from the title, see Matcher.StripParentheticals
+	Parentheticals []string `json:"parentheticals,omitempty"`
+	// Where the stripped manufacturer was in OriginalTitle, nil when unmatched
+	Span *Span `json:"span,omitempty"`
+	// Other manufacturers found in the title, e.g. the partners of a consortium
+	AlsoMatched []string `json:"alsoMatched,omitempty"`
+	// The aircraft was left as it is, see Matcher.alreadyClean
+	AlreadyClean bool `json:"alreadyClean,omitempty"`
+}
+
+// Span is the byte range [Start, End) of some text
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (r Resolution) Matched() bool {
+	return r.Manufacturer != ""
+}
+
+// sameAs reports whether writing the resolution would leave the aircraft as
+// it is, which includes not matching at all
+func (r Resolution) sameAs(a Aircraft) bool {
+	return !r.Matched() || (r.Manufacturer == a.Manufacturer && r.Title == a.Title)
+}
+
+// Matcher finds manufacturers in aircraft titles
+type Matcher struct {
+	Manufacturers []Manufacturer
+	StripPrefixes []string // Noise words removed from the start of titles before matching
+	Mode          string   // Where in the title the manufacturer may appear, see matchModes
+	Connectors    []string // Words like "by" left dangling before a stripped trailing manufacturer
+	Canonical     bool     // Put the manufacturer's canonical name back in front of the stripped title
+	MaxTitleLen   int      // Only the first this many bytes are matched and stripped titles are cut to it, 0 for no limit
+	Phonetic      bool     // Fall back to matching names by how they sound
+	Rules         []string // Methods to match by, in order, see findCandidates
+	// Remove parenthetical clauses from stripped titles, keeping them in
+	// Resolution.Parentheticals
+	StripParentheticals bool
+	// How cleanTitle recases titles, see caseModes; "" leaves them alone
+	TitleCase        string
+	CleanPunctuation bool // Have cleanTitle tidy up punctuation, see cleanPunctuation
+	// Manufacturer IDs assigned to aircraft by aircraft ID, ahead of any
+	// matching, see resolveOverride
+	Overrides map[string]string
+
+	// When set, replaces the exact method's per-manufacturer search; not
+	// used by runs, see combinedRegex
+	combined *combinedRegex
+}
+
+const (
+	modeAnywhere = "anywhere"
+	modePrefix   = "prefix"
+	modeSuffix   = "suffix"
+)
+
+var matchModes = []string{modeAnywhere, modePrefix, modeSuffix}
+
+// candidate is one manufacturer found in a title
+type candidate struct {
+	Manufacturer Manufacturer
+	// Byte offsets of the text to remove from the title, usually the
+	// manufacturer's name
+	Start, End int
+	Model      string // Model captured by the manufacturer's pattern
+	Method     string
+}
+
+// truncate cuts the title down to MaxTitleLen bytes, at a word boundary
+// where there is one. It is applied twice: to the normalized title, giving
+// the window manufacturers are looked for in so names buried deep in a long
+// blurb aren't matched, and to the stripped title that gets stored, so the
+// manufacturer's name doesn't use up the budget. A title it would cut to
+// nothing is left as it is.
+func (m Matcher) truncate(title string) (string, bool) {
+	if m.MaxTitleLen <= 0 || len(title) <= m.MaxTitleLen {
+		return title, false
+	}
+	n := m.MaxTitleLen
+	if title[n] != ' ' {
+		if i := strings.LastIndexByte(title[:n], ' '); i > 0 {
+			n = i
+		}
+	}
+	// don't leave half a UTF-8 sequence behind; only the end moves, so the
+	// window's offsets still hold in title
+	for n > 0 && !utf8.RuneStart(title[n]) {
+		n--
+	}
+	cut := strings.TrimRight(title[:n], " ")
+	if cut == "" {
+		return title, false
+	}
+
+	return cut, true
+}
+
+// normalize collapses runs of whitespace and removes any leading noise words
+func (m Matcher) normalize(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	for stripped := true; stripped; {
+		stripped = false
+		for _, prefix := range m.StripPrefixes {
+			if rest, ok := cutWordPrefix(title, prefix); ok {
+				title = rest
+				stripped = true
+			}
+		}
+	}
+
+	return title
+}
+
+// cutWordPrefix removes prefix from the start of title, ignoring case, as long
+// as it is a whole word. Prefixes ending in punctuation, like "Ex-", may run
+// straight into the next word.
+func cutWordPrefix(title, prefix string) (string, bool) {
+	if prefix == "" || len(title) < len(prefix) || !strings.EqualFold(title[:len(prefix)], prefix) {
+		return title, false
+	}
+	rest := title[len(prefix):]
+	last := prefix[len(prefix)-1]
+	wordEnd := rest == "" || rest[0] == ' ' || !isWordByte(last)
+	if !wordEnd {
+		return title, false
+	}
+
+	return strings.TrimSpace(rest), true
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b >= 0x80
+}
+
+// findCandidates returns every manufacturer found in the title. Manufacturers
+// with a pattern are identified by it instead of by name, and its "model"
+// group, if any, is captured alongside.
+//
+// Parenthetical clauses are ignored, since they're usually legal noise like
+// "(a subsidiary of ...)", except by the parenthetical method that looks for
+// tokens in them.
+//
+// Without Rules each manufacturer is looked for by every method in turn.
+// With Rules only the listed methods are used, one at a time across all
+// manufacturers, and the first method that finds anything wins.
+func (m Matcher) findCandidates(title string) []candidate {
+	masked := maskParentheticals(title)
+	if len(m.Rules) > 0 {
+		for _, method := range m.Rules {
+			if found := m.findCandidatesBy(title, masked, method); len(found) > 0 {
+				return found
+			}
+		}
+		return nil
+	}
+
+	var found []candidate
+	var exact map[string]int
+	if m.combined != nil {
+		exact = m.combined.find(m, masked)
+	}
+	for _, mf := range m.Manufacturers {
+		if mf.excluded(title) {
+			continue
+		}
+		for _, method := range defaultRules {
+			var c candidate
+			var ok bool
+			if method == methodExact && exact != nil {
+				c, ok = exactCandidate(mf, exact)
+			} else {
+				c, ok = m.candidateBy(title, masked, mf, method)
+			}
+			if ok {
+				found = append(found, c)
+				break
+			}
+		}
+	}
+	if len(found) == 0 && m.Phonetic {
+		found = m.findCandidatesBy(title, masked, methodPhonetic)
+	}
+
+	return found
+}
+
+func (m Matcher) findCandidatesBy(title, masked, method string) []candidate {
+	var exact map[string]int
+	if method == methodExact && m.combined != nil {
+		exact = m.combined.find(m, masked)
+	}
+	var found []candidate
+	for _, mf := range m.Manufacturers {
+		if mf.excluded(title) {
+			continue
+		}
+		var c candidate
+		var ok bool
+		if exact != nil {
+			c, ok = exactCandidate(mf, exact)
+		} else {
+			c, ok = m.candidateBy(title, masked, mf, method)
+		}
+		if ok {
+			found = append(found, c)
+		}
+	}
+
+	return found
+}
+
+// candidateBy looks for mf in title using only the given method. Manufacturers
+// with a pattern are only ever matched by it, or phonetically. masked is the
+// title with its parenthetical clauses blanked out.
+func (m Matcher) candidateBy(title, masked string, mf Manufacturer, method string) (candidate, bool) {
+	if (mf.pattern != nil) != (method == methodRegex) && method != methodPhonetic {
+		return candidate{}, false
+	}
+	if method != methodParenthetical {
+		title = masked
+	}
+	switch method {
+	case methodRegex:
+		loc := mf.pattern.FindStringSubmatchIndex(title)
+		if loc == nil || !m.allowedAt(title, loc[0], loc[1]) {
+			return candidate{}, false
+		}
+		// only the name is stripped, when it's part of what the pattern
+		// matched; patterns may ignore case, so the name may too when it's a
+		// whole word
+		c := candidate{Manufacturer: mf, Start: loc[0], End: loc[0], Method: methodRegex}
+		matched := title[loc[0]:loc[1]]
+		i := strings.Index(matched, mf.Name)
+		if i < 0 {
+			if j, ok := (Matcher{}).findWord(matched, mf.Name); ok {
+				i = j
+			}
+		}
+		if i >= 0 && mf.Name != "" {
+			c.Start, c.End = loc[0]+i, loc[0]+i+len(mf.Name)
+		}
+		if i := mf.pattern.SubexpIndex("model"); i >= 0 && loc[2*i] >= 0 {
+			c.Model = strings.TrimSpace(title[loc[2*i]:loc[2*i+1]])
+		}
+		return c, true
+	case methodExact:
+		if start, ok := m.findExact(title, mf.Name); ok && m.allowedAt(title, start, start+len(mf.Name)) {
+			return candidate{Manufacturer: mf, Start: start, End: start + len(mf.Name), Method: methodExact}, true
+		}
+	case methodWord:
+		if start, ok := m.findWord(title, mf.Name); ok {
+			return candidate{Manufacturer: mf, Start: start, End: start + len(mf.Name), Method: methodWord}, true
+		}
+	case methodParenthetical:
+		if start, end, ok := findParenthetical(title, mf.Parentheticals); ok && m.allowedAt(title, start, end) {
+			return candidate{Manufacturer: mf, Start: start, End: end, Method: methodParenthetical}, true
+		}
+	case methodPhonetic:
+		if start, end, ok := findPhonetic(title, mf.Name); ok && m.allowedAt(title, start, end) {
+			return candidate{Manufacturer: mf, Start: start, End: end, Method: methodPhonetic}, true
+		}
+	}
+
+	return candidate{}, false
+}
+
+// findWord returns where name appears in title as a whole word, ignoring
+// case. In suffix mode the last such appearance is used.
+func (m Matcher) findWord(title, name string) (int, bool) {
+	found := -1
+	for i := 0; name != "" && i+len(name) <= len(title); i++ {
+		if !strings.EqualFold(title[i:i+len(name)], name) || !isWordBoundary(title, i, i+len(name)) {
+			continue
+		}
+		found = i
+		if m.Mode != modeSuffix {
+			break
+		}
+	}
+	if found < 0 || !m.allowedAt(title, found, found+len(name)) {
+		return 0, false
+	}
+
+	return found, true
+}
+
+// findExact returns where name appears in title, the last appearance in
+// suffix mode. A name of several words only matches whole words, so "de
+// Havilland Canada" isn't found in "de Havilland Canadair".
+func (m Matcher) findExact(title, name string) (int, bool) {
+	if name == "" {
+		return 0, false
+	}
+	compound := strings.Contains(name, " ")
+	found := -1
+	for offset := 0; ; {
+		i := strings.Index(title[offset:], name)
+		if i < 0 {
+			break
+		}
+		i += offset
+		if !compound || isWordBoundary(title, i, i+len(name)) {
+			found = i
+			if m.Mode != modeSuffix {
+				break
+			}
+		}
+		offset = i + 1
+	}
+
+	return found, found >= 0
+}
+
+// isWordBoundary reports whether title[start:end] isn't part of a longer word
+func isWordBoundary(title string, start, end int) bool {
+	return (start == 0 || !isWordByte(title[start-1])) && (end == len(title) || !isWordByte(title[end]))
+}
+
+// findParenthetical returns the span, parentheses included, of the first
+// parenthetical in title whose contents are one of tokens, ignoring case
+func findParenthetical(title string, tokens []string) (int, int, bool) {
+	if len(tokens) == 0 {
+		return 0, 0, false
+	}
+	for offset := 0; ; {
+		open := strings.IndexByte(title[offset:], '(')
+		if open < 0 {
+			return 0, 0, false
+		}
+		open += offset
+		closing := strings.IndexByte(title[open:], ')')
+		if closing < 0 {
+			return 0, 0, false
+		}
+		closing += open
+		inner := strings.TrimSpace(title[open+1 : closing])
+		for _, token := range tokens {
+			if strings.EqualFold(inner, token) {
+				return open, closing + 1, true
+			}
+		}
+		offset = closing + 1
+	}
+}
+
+// excluded reports whether one of the manufacturer's exclude patterns
+// matches the title
+func (mf Manufacturer) excluded(title string) bool {
+	for _, re := range mf.excludes {
+		if re.MatchString(title) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowedAt reports whether a match spanning title[start:end] fits the mode
+func (m Matcher) allowedAt(title string, start, end int) bool {
+	switch m.Mode {
+	case modePrefix:
+		return start == 0
+	case modeSuffix:
+		return end == len(title)
+	}
+
+	return true
+}
+
+// pickCandidate chooses between several manufacturers found in one title: the
+// one named first wins, and of those starting at the same place the longest
+// name wins, so "de Havilland Canada" beats "de Havilland".
+func pickCandidate(candidates []candidate) candidate {
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.Start < best.Start || (c.Start == best.Start && len(c.Manufacturer.Name) > len(best.Manufacturer.Name)) {
+			best = c
+		}
+	}
+
+	return best
+}
+
+// resolve finds the manufacturer named in the aircraft's title and strips it.
+// Every mode resolves through here, so an aircraft already in the clean form
+// is left as it is whatever reads the result.
+func (m Matcher) resolve(a Aircraft) Resolution {
+	// stripping again would take the manufacturer off the model
+	if m.alreadyClean(a) {
+		return Resolution{ID: a.ID, OriginalTitle: a.Title, Title: a.Title, Manufacturer: a.Manufacturer, AlreadyClean: true}
+	}
+	if id, ok := m.Overrides[a.ID]; ok {
+		return m.resolveOverride(a, id)
+	}
+	title := m.normalize(a.Title)
+	window, _ := m.truncate(title)
+	candidates := m.findCandidates(window)
+	if len(candidates) == 0 {
+		return unresolved(a, title)
+	}
+	best := pickCandidate(candidates)
+	res := m.resolveAs(a, title, best)
+	for _, c := range candidates {
+		if id := c.Manufacturer.ID; id != best.Manufacturer.ID && !slices.Contains(res.AlsoMatched, id) {
+			res.AlsoMatched = append(res.AlsoMatched, id)
+		}
+	}
+
+	return res
+}
+
+// trimConnector removes one trailing connector word, ignoring case, so
+// "737-800 by" becomes "737-800"
+func (m Matcher) trimConnector(title string) string {
+	for _, connector := range m.Connectors {
+		n := len(title) - len(connector)
+		if n < 0 || !strings.EqualFold(title[n:], connector) {
+			continue
+		}
+		if n == 0 || title[n-1] == ' ' {
+			return strings.TrimSpace(title[:n])
+		}
+	}
+
+	return title
+}
+
+// alreadyClean reports whether the aircraft is already in the clean
+// "<canonical name> <model>" form for the manufacturer it refers to. That's
+// what -canonical-title writes, or a title someone deliberately put back.
+func (m Matcher) alreadyClean(a Aircraft) bool {
+	if a.Manufacturer == "" {
+		return false
+	}
+	if id, ok := m.Overrides[a.ID]; ok && id != a.Manufacturer {
+		return false
+	}
+	for _, mf := range m.Manufacturers {
+		if mf.ID != a.Manufacturer {
+			continue
+		}
+		model, ok := strings.CutPrefix(a.Title, mf.Name+" ")
+		return ok && strings.TrimSpace(model) != ""
+	}
+
+	return false
+}
+
+// originalSpan maps [start, end) in title, the normalized form of original,
+// back to the same text in original. Normalizing only collapses whitespace,
+// drops noise words from the front and truncates the end.
+func (m Matcher) originalSpan(original, title string, start, end int) Span {
+	offsets := collapsedOffsets(original)
+	front := len(offsets) - 1 - len(m.normalize(original))
+
+	return Span{Start: offsets[front+start], End: offsets[front+end]}
+}
+
+// collapsedOffsets returns, for every byte of title with its whitespace
+// collapsed as strings.Fields does, where that byte is in title. The extra
+// last entry is the end of the last word.
+func collapsedOffsets(title string) []int {
+	var offsets []int
+	inWord := false
+	for i := 0; i < len(title); {
+		r, size := utf8.DecodeRuneInString(title[i:])
+		if unicode.IsSpace(r) {
+			if inWord {
+				// the single space standing for this run, or the end of
+				// the last word
+				offsets = append(offsets, i)
+			}
+			inWord = false
+		} else {
+			for n := range size {
+				offsets = append(offsets, i+n)
+			}
+			inWord = true
+		}
+		i += size
+	}
+	if inWord || len(offsets) == 0 {
+		offsets = append(offsets, len(title))
+	}
+
+	return offsets
+}
+
+func unresolved(a Aircraft, title string) Resolution {
+	return Resolution{ID: a.ID, OriginalTitle: a.Title, Title: title}
+}
+
+// resolveAs cuts the chosen manufacturer out of the normalized title at the
+// offsets it was found at, so a repeated name or a second manufacturer
+// mentioned elsewhere in the title is kept. In suffix mode any connector word
+// left in front of it goes too.
+func (m Matcher) resolveAs(a Aircraft, title string, c candidate) Resolution {
+	span := m.originalSpan(a.Title, title, c.Start, c.End)
+	title = strings.TrimSpace(title[:c.Start] + " " + title[c.End:])
+	if m.Mode == modeSuffix {
+		title = m.trimConnector(title)
+	}
+	res := Resolution{
+		ID:            a.ID,
+		OriginalTitle: a.Title,
+		Manufacturer:  c.Manufacturer.ID,
+		Model:         c.Model,
+		Method:        c.Method,
+		LostCode:      lostCode(a, title),
+		Span:          &span,
+	}
+	if m.StripParentheticals {
+		title, res.Parentheticals = removeParentheticals(title)
+	}
+	title = strings.Join(strings.Fields(title), " ")
+	if m.Canonical {
+		title = strings.TrimSpace(c.Manufacturer.Name + " " + title)
+	}
+	res.Title, res.Truncated = m.truncate(title)
+
+	return res
+}
+
+// lostCode returns the ICAO or IATA code that the original title contained
+// but the stripped title no longer does, meaning the manufacturer name was
+// part of the model code rather than a separate word.
+func lostCode(a Aircraft, stripped string) string {
+	for _, code := range []string{a.Icao, a.Iata} {
+		if code != "" && strings.Contains(a.Title, code) && !strings.Contains(stripped, code) {
+			return code
+		}
+	}
+
+	return ""
+}
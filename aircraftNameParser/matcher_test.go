@@ -0,0 +1,449 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testManufacturers builds manufacturers the way loadManufacturers does
+func testManufacturers(t *testing.T, manufacturers ...Manufacturer) []Manufacturer {
+	t.Helper()
+	for i := range manufacturers {
+		if err := manufacturers[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return manufacturers
+}
+
+func TestResolveTruncatesStrippedTitle(t *testing.T) {
+	tests := []struct {
+		title     string
+		maxLen    int
+		want      string
+		truncated bool
+	}{
+		{"Airbus A320neo extra", 10, "A320neo", true},
+		{"Airbus A320neo", 10, "A320neo", false},
+		{"Airbus A320neoextended", 10, "A320neoext", true},
+	}
+	m := Matcher{Manufacturers: testManufacturers(t, Manufacturer{ID: "airbus", Name: "Airbus"})}
+	for _, tt := range tests {
+		m.MaxTitleLen = tt.maxLen
+		res := m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Manufacturer != "airbus" || res.Title != tt.want || res.Truncated != tt.truncated {
+			t.Errorf("resolve(%q) with max %d = %s %q truncated=%v, want airbus %q truncated=%v", tt.title, tt.maxLen, res.Manufacturer, res.Title, res.Truncated, tt.want, tt.truncated)
+		}
+	}
+}
+
+func TestTruncateNeverEmpties(t *testing.T) {
+	// cutting inside the first rune would leave nothing
+	m := Matcher{MaxTitleLen: 1}
+	if got, truncated := m.truncate("Ébc"); got != "Ébc" || truncated {
+		t.Errorf("truncate(%q) = %q, %v, want it left as it is", "Ébc", got, truncated)
+	}
+}
+
+func TestResolveMatchesOnlyInWindow(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t, Manufacturer{ID: "boeing", Name: "Boeing"}), MaxTitleLen: 12}
+	res := m.resolve(Aircraft{ID: "1", Title: "Jumbo jet once flown by Boeing"})
+	if res.Matched() {
+		t.Errorf("matched %s outside the first 12 bytes", res.Manufacturer)
+	}
+}
+
+func TestResolveAlreadyClean(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t, Manufacturer{ID: "boeing", Name: "Boeing"})}
+	tests := []struct {
+		a     Aircraft
+		clean bool
+		title string
+	}{
+		{Aircraft{ID: "1", Manufacturer: "boeing", Title: "Boeing 737"}, true, "Boeing 737"},
+		{Aircraft{ID: "2", Title: "Boeing 737"}, false, "737"},
+		{Aircraft{ID: "3", Manufacturer: "boeing", Title: "Boeing"}, false, ""},
+	}
+	for _, tt := range tests {
+		res := m.resolve(tt.a)
+		if res.AlreadyClean != tt.clean || res.Title != tt.title {
+			t.Errorf("resolve(%+v) = clean %v %q, want clean %v %q", tt.a, res.AlreadyClean, res.Title, tt.clean, tt.title)
+		}
+	}
+}
+
+func TestResolveStripsOneOccurrence(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t,
+		Manufacturer{ID: "airbus", Name: "Airbus"},
+		Manufacturer{ID: "boeing", Name: "Boeing"},
+	)}
+	tests := []struct {
+		title        string
+		manufacturer string
+		want         string
+		also         []string
+	}{
+		// a repeated name only loses the occurrence that was matched
+		{"Boeing Boeing 747", "boeing", "Boeing 747", nil},
+		// the manufacturer named first wins and the other is kept
+		{"Airbus sold to Boeing", "airbus", "sold to Boeing", []string{"boeing"}},
+		{"Boeing 747 sold to Airbus", "boeing", "747 sold to Airbus", []string{"airbus"}},
+	}
+	for _, tt := range tests {
+		res := m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Manufacturer != tt.manufacturer || res.Title != tt.want || !slices.Equal(res.AlsoMatched, tt.also) {
+			t.Errorf("resolve(%q) = %s %q also %v, want %s %q also %v", tt.title, res.Manufacturer, res.Title, res.AlsoMatched, tt.manufacturer, tt.want, tt.also)
+		}
+	}
+}
+
+func TestPickCandidate(t *testing.T) {
+	dh := Manufacturer{ID: "dh", Name: "de Havilland"}
+	dhc := Manufacturer{ID: "dhc", Name: "de Havilland Canada"}
+	boeing := Manufacturer{ID: "boeing", Name: "Boeing"}
+	tests := []struct {
+		candidates []candidate
+		want       string
+	}{
+		{[]candidate{{Manufacturer: boeing, Start: 15}, {Manufacturer: dh, Start: 0}}, "dh"},
+		{[]candidate{{Manufacturer: dh, Start: 0}, {Manufacturer: dhc, Start: 0}}, "dhc"},
+		{[]candidate{{Manufacturer: dhc, Start: 0}, {Manufacturer: dh, Start: 0}}, "dhc"},
+	}
+	for _, tt := range tests {
+		if got := pickCandidate(tt.candidates); got.Manufacturer.ID != tt.want {
+			t.Errorf("pickCandidate(%v) = %s, want %s", tt.candidates, got.Manufacturer.ID, tt.want)
+		}
+	}
+}
+
+func TestResolveStripsPrefixes(t *testing.T) {
+	m := Matcher{
+		Manufacturers: testManufacturers(t, Manufacturer{ID: "airbus", Name: "Airbus"}, Manufacturer{ID: "boeing", Name: "Boeing"}),
+		StripPrefixes: []string{"The", "New", "Ex-"},
+		Mode:          modePrefix,
+	}
+	tests := []struct {
+		title        string
+		manufacturer string
+		want         string
+	}{
+		{"The Boeing 737", "boeing", "737"},
+		{"Ex-Airbus A320", "airbus", "A320"},
+		{"the new  Boeing 737", "boeing", "737"},
+		{"Ex- Airbus A320", "airbus", "A320"},
+	}
+	for _, tt := range tests {
+		res := m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Manufacturer != tt.manufacturer || res.Title != tt.want {
+			t.Errorf("resolve(%q) = %s %q, want %s %q", tt.title, res.Manufacturer, res.Title, tt.manufacturer, tt.want)
+		}
+	}
+}
+
+func TestCutWordPrefix(t *testing.T) {
+	tests := []struct {
+		title, prefix string
+		want          string
+		ok            bool
+	}{
+		{"The Boeing 737", "The", "Boeing 737", true},
+		{"the Boeing 737", "The", "Boeing 737", true},
+		{"Ex-Airbus A320", "Ex-", "Airbus A320", true},
+		// only whole words
+		{"Theodore 1", "The", "Theodore 1", false},
+		{"Newport 2", "New", "Newport 2", false},
+		{"The", "The", "", true},
+		{"Th", "The", "Th", false},
+		{"Boeing 737", "", "Boeing 737", false},
+	}
+	for _, tt := range tests {
+		if got, ok := cutWordPrefix(tt.title, tt.prefix); got != tt.want || ok != tt.ok {
+			t.Errorf("cutWordPrefix(%q, %q) = %q, %v, want %q, %v", tt.title, tt.prefix, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestResolveSuffix(t *testing.T) {
+	m := Matcher{
+		Manufacturers: testManufacturers(t, Manufacturer{ID: "airbus", Name: "Airbus"}, Manufacturer{ID: "boeing", Name: "Boeing"}),
+		Mode:          modeSuffix,
+		Connectors:    []string{"by", "from"},
+	}
+	tests := []struct {
+		title        string
+		manufacturer string
+		want         string
+	}{
+		{"737-800 by Boeing", "boeing", "737-800"},
+		{"A320 From Airbus", "airbus", "A320"},
+		{"737-800 Boeing", "boeing", "737-800"},
+		// only a connector word goes, not a word ending in one
+		{"Hangar nearby Boeing", "boeing", "Hangar nearby"},
+		// the last manufacturer is the trailing one
+		{"Airbus A320 by Boeing", "boeing", "Airbus A320"},
+		{"Boeing 747 by Airbus", "airbus", "Boeing 747"},
+		// not at the end, so not matched
+		{"Boeing 737", "", "Boeing 737"},
+	}
+	for _, tt := range tests {
+		res := m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Manufacturer != tt.manufacturer || res.Title != tt.want {
+			t.Errorf("resolve(%q) = %s %q, want %s %q", tt.title, res.Manufacturer, res.Title, tt.manufacturer, tt.want)
+		}
+	}
+}
+
+func TestTrimConnector(t *testing.T) {
+	m := Matcher{Connectors: []string{"by", "from"}}
+	tests := []struct{ title, want string }{
+		{"737-800 by", "737-800"},
+		{"A320 FROM", "A320"},
+		{"nearby", "nearby"},
+		{"Hangar nearby", "Hangar nearby"},
+		{"by", ""},
+		{"737-800", "737-800"},
+		// only one goes
+		{"737 by by", "737 by"},
+	}
+	for _, tt := range tests {
+		if got := m.trimConnector(tt.title); got != tt.want {
+			t.Errorf("trimConnector(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedAt(t *testing.T) {
+	title := "Boeing 737 by Boeing"
+	tests := []struct {
+		mode       string
+		start, end int
+		want       bool
+	}{
+		{modeAnywhere, 7, 10, true},
+		{modePrefix, 0, 6, true},
+		{modePrefix, 14, 20, false},
+		{modeSuffix, 14, 20, true},
+		{modeSuffix, 0, 6, false},
+	}
+	for _, tt := range tests {
+		m := Matcher{Mode: tt.mode}
+		if got := m.allowedAt(title, tt.start, tt.end); got != tt.want {
+			t.Errorf("%s allowedAt(%d, %d) = %v, want %v", tt.mode, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestResolveParentheticalToken(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t,
+		Manufacturer{ID: "airbus", Name: "Airbus", Parentheticals: []string{"ABS"}},
+		Manufacturer{ID: "boeing", Name: "Boeing"},
+	)}
+	tests := []struct {
+		title        string
+		manufacturer string
+		want         string
+		method       string
+	}{
+		{"A320 (ABS)", "airbus", "A320", methodParenthetical},
+		{"A320 ( abs ) neo", "airbus", "A320 neo", methodParenthetical},
+		// the name in the title beats the token
+		{"Airbus A320 (ABS)", "airbus", "A320 (ABS)", methodExact},
+		// a token is only matched when it's the whole parenthetical
+		{"A320 (ABS brakes)", "", "A320 (ABS brakes)", ""},
+		{"A320 ABS", "", "A320 ABS", ""},
+		// and not by manufacturers named in the parenthetical
+		{"A320 (Boeing)", "", "A320 (Boeing)", ""},
+	}
+	for _, tt := range tests {
+		res := m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Manufacturer != tt.manufacturer || res.Title != tt.want || res.Method != tt.method {
+			t.Errorf("resolve(%q) = %s %q by %q, want %s %q by %q", tt.title, res.Manufacturer, res.Title, res.Method, tt.manufacturer, tt.want, tt.method)
+		}
+	}
+}
+
+func TestFindParenthetical(t *testing.T) {
+	tests := []struct {
+		title      string
+		start, end int
+		ok         bool
+	}{
+		{"A320 (ABS)", 5, 10, true},
+		{"A320 (neo) (abs)", 11, 16, true},
+		{"A320 (ABS", 0, 0, false},
+		{"A320 ABS)", 0, 0, false},
+		{"A320 ()", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, ok := findParenthetical(tt.title, []string{"ABS"})
+		if start != tt.start || end != tt.end || ok != tt.ok {
+			t.Errorf("findParenthetical(%q) = %d, %d, %v, want %d, %d, %v", tt.title, start, end, ok, tt.start, tt.end, tt.ok)
+		}
+	}
+	if _, _, ok := findParenthetical("A320 (ABS)", nil); ok {
+		t.Error("found a parenthetical without tokens")
+	}
+}
+
+func TestResolveSpan(t *testing.T) {
+	manufacturers := testManufacturers(t, Manufacturer{ID: "airbus", Name: "Airbus"}, Manufacturer{ID: "boeing", Name: "Boeing"})
+	tests := []struct {
+		m     Matcher
+		title string
+		want  Span
+	}{
+		{Matcher{}, "Boeing 737", Span{0, 6}},
+		{Matcher{}, "  Boeing   737", Span{2, 8}},
+		{Matcher{}, "737   by  Boeing", Span{10, 16}},
+		{Matcher{}, "737\t\nby Boeing ", Span{8, 14}},
+		{Matcher{}, "  Été Airbus", Span{8, 14}},
+		{Matcher{StripPrefixes: []string{"The", "Ex-"}}, "The   Boeing 737", Span{6, 12}},
+		{Matcher{StripPrefixes: []string{"The", "Ex-"}}, " the  Ex-Airbus  A320", Span{9, 15}},
+		{Matcher{MaxTitleLen: 12}, "  Boeing 737 with a long tail", Span{2, 8}},
+		{Matcher{Mode: modeSuffix, Connectors: []string{"by"}, MaxTitleLen: 20}, "A320   by   Airbus", Span{12, 18}},
+	}
+	for _, tt := range tests {
+		tt.m.Manufacturers = manufacturers
+		res := tt.m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Span == nil || *res.Span != tt.want {
+			t.Errorf("resolve(%q) span = %v, want %v", tt.title, res.Span, tt.want)
+			continue
+		}
+		if got := tt.title[res.Span.Start:res.Span.End]; got != "Airbus" && got != "Boeing" {
+			t.Errorf("resolve(%q) span covers %q", tt.title, got)
+		}
+	}
+}
+
+func TestCollapsedOffsets(t *testing.T) {
+	tests := []struct {
+		title string
+		want  []int
+	}{
+		{"", []int{0}},
+		{"ab", []int{0, 1, 2}},
+		{"a  b", []int{0, 1, 3, 4}},
+		{"  a\t\tb  ", []int{2, 3, 5, 6}},
+		{"é b", []int{0, 1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		if got := collapsedOffsets(tt.title); !slices.Equal(got, tt.want) {
+			t.Errorf("collapsedOffsets(%q) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestResolveCompoundName(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t,
+		Manufacturer{ID: "dh", Name: "de Havilland"},
+		Manufacturer{ID: "dhc", Name: "de Havilland Canada"},
+	)}
+	tests := []struct {
+		title        string
+		manufacturer string
+		want         string
+		method       string
+		span         Span
+	}{
+		{"de Havilland Canada DHC-6", "dhc", "DHC-6", methodExact, Span{0, 19}},
+		{"De Havilland  Canada DHC-6", "dhc", "DHC-6", methodWord, Span{0, 20}},
+		{"DE HAVILLAND CANADA DHC-8", "dhc", "DHC-8", methodWord, Span{0, 19}},
+		{"Dash 7 by de\thavilland   canada", "dhc", "Dash 7 by", methodWord, Span{10, 31}},
+		// not a longer word that starts with the last one
+		{"de Havilland Canadair", "dh", "Canadair", methodExact, Span{0, 12}},
+		{"DE HAVILLAND CANADAIR", "dh", "CANADAIR", methodWord, Span{0, 12}},
+	}
+	for _, tt := range tests {
+		res := m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Manufacturer != tt.manufacturer || res.Title != tt.want || res.Method != tt.method {
+			t.Errorf("resolve(%q) = %s %q by %q, want %s %q by %q", tt.title, res.Manufacturer, res.Title, res.Method, tt.manufacturer, tt.want, tt.method)
+		}
+		if res.Span == nil || *res.Span != tt.span {
+			t.Errorf("resolve(%q) span = %v, want %v", tt.title, res.Span, tt.span)
+		}
+	}
+}
+
+func TestFindExactAndWord(t *testing.T) {
+	const name = "de Havilland Canada"
+	tests := []struct {
+		title string
+		mode  string
+		exact int // -1 when not found
+		word  int
+	}{
+		{"de Havilland Canada DHC-6", modeAnywhere, 0, 0},
+		{"De Havilland Canada DHC-6", modeAnywhere, -1, 0},
+		{"the DE HAVILLAND CANADA", modeAnywhere, -1, 4},
+		{"de Havilland Canadair", modeAnywhere, -1, -1},
+		{"xde Havilland Canada", modeAnywhere, -1, -1},
+		{"de Havilland Canada, de Havilland Canada", modeAnywhere, 0, 0},
+		{"de Havilland Canada, de Havilland Canada", modeSuffix, 21, 21},
+		{"DE HAVILLAND CANADA X", modeSuffix, -1, -1},
+		{"X de havilland canada", modePrefix, -1, -1},
+	}
+	for _, tt := range tests {
+		m := Matcher{Mode: tt.mode}
+		if start, ok := m.findExact(tt.title, name); ok != (tt.exact >= 0) || ok && start != tt.exact {
+			t.Errorf("%s findExact(%q) = %d, %v, want %d", tt.mode, tt.title, start, ok, tt.exact)
+		}
+		if start, ok := m.findWord(tt.title, name); ok != (tt.word >= 0) || ok && start != tt.word {
+			t.Errorf("%s findWord(%q) = %d, %v, want %d", tt.mode, tt.title, start, ok, tt.word)
+		}
+	}
+}
+
+func TestResolvePatternStripsName(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t,
+		Manufacturer{ID: "boeing", Name: "Boeing", Pattern: `(?i)boeing (?P<model>7\d7)`},
+		Manufacturer{ID: "airbus", Name: "Airbus", Pattern: `Airbus(?P<model>A3\d\d)`},
+	)}
+	tests := []struct {
+		title        string
+		manufacturer string
+		model        string
+		want         string
+	}{
+		{"Boeing 737-800", "boeing", "737", "737-800"},
+		{"boeing 737-800", "boeing", "737", "737-800"},
+		{"BOEING 747 freighter", "boeing", "747", "747 freighter"},
+		// the name doesn't have to be a word of its own when the case matches
+		{"AirbusA320", "airbus", "A320", "A320"},
+	}
+	for _, tt := range tests {
+		res := m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Manufacturer != tt.manufacturer || res.Model != tt.model || res.Title != tt.want {
+			t.Errorf("resolve(%q) = %s %q %q, want %s %q %q", tt.title, res.Manufacturer, res.Model, res.Title, tt.manufacturer, tt.model, tt.want)
+		}
+	}
+}
+
+func TestTruncateKeepsPrefix(t *testing.T) {
+	m := Matcher{MaxTitleLen: 5}
+	tests := []struct{ title, want string }{
+		// only the rune split by the cut goes
+		{"abcdé fgh", "abcd"},
+		{"a\xe9cdefgh", "a\xe9cde"},
+		{"A\xe9 Boeing", "A\xe9"},
+	}
+	for _, tt := range tests {
+		got, _ := m.truncate(tt.title)
+		if got != tt.want || !strings.HasPrefix(tt.title, got) {
+			t.Errorf("truncate(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestResolveInvalidByteBeforeMatch(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t, Manufacturer{ID: "boeing", Name: "Boeing"}), MaxTitleLen: 20}
+	title := "A\xe9 Boeing 737 long long long long"
+	res := m.resolve(Aircraft{ID: "1", Title: title})
+	if res.Manufacturer != "boeing" || res.Title != "A\xe9 737 long long" {
+		t.Errorf("resolve(%q) = %s %q, want boeing %q", title, res.Manufacturer, res.Title, "A\xe9 737 long long")
+	}
+	if res.Span == nil || title[res.Span.Start:res.Span.End] != "Boeing" {
+		t.Errorf("resolve(%q) span = %v, want it on Boeing", title, res.Span)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Ways cleanTitle can recase titles
+const (
+	caseKeep  = "keep"
+	caseTitle = "title" // Capitalize the first letter of every word, leaving the rest alone
+	caseUpper = "upper"
+	caseLower = "lower"
+)
+
+var caseModes = []string{caseKeep, caseTitle, caseUpper, caseLower}
+
+// cleanTitle applies the title clean-up steps without matching anything:
+// whitespace and noise words, the length limit and, if asked, parenthetical
+// clauses, punctuation and case
+func (m Matcher) cleanTitle(title string) (string, []string) {
+	title = m.normalize(title)
+	var removed []string
+	if m.StripParentheticals {
+		title, removed = removeParentheticals(title)
+	}
+	if m.CleanPunctuation {
+		title = cleanPunctuation(title)
+	}
+	title = recase(strings.Join(strings.Fields(title), " "), m.TitleCase)
+	// last, so removed clauses don't count against the limit
+	title, _ = m.truncate(title)
+
+	return title, removed
+}
+
+// cleanPunctuation drops the spaces in front of ",;:!?", collapses repeats
+// of one of them and trims separators left dangling at either end, so "- 737
+// ,, MAX ," becomes "737, MAX". A title that would be left empty, like
+// "- , -", is kept as it is.
+func cleanPunctuation(title string) string {
+	cleaned := make([]byte, 0, len(title))
+	for i := 0; i < len(title); i++ {
+		c := title[i]
+		if strings.IndexByte(",;:!?", c) >= 0 {
+			cleaned = bytes.TrimRight(cleaned, " ")
+			if len(cleaned) > 0 && cleaned[len(cleaned)-1] == c {
+				continue
+			}
+		}
+		cleaned = append(cleaned, c)
+	}
+	if trimmed := strings.Trim(string(cleaned), " ,;:-/"); trimmed != "" {
+		return trimmed
+	}
+
+	return title
+}
+
+// recase changes the case of title as mode says, see caseModes
+func recase(title, mode string) string {
+	switch mode {
+	case caseUpper:
+		return strings.ToUpper(title)
+	case caseLower:
+		return strings.ToLower(title)
+	case caseTitle:
+		words := strings.Split(title, " ")
+		for i, word := range words {
+			r, size := utf8.DecodeRuneInString(word)
+			if r != utf8.RuneError {
+				words[i] = string(unicode.ToUpper(r)) + word[size:]
+			}
+		}
+		return strings.Join(words, " ")
+	}
+
+	return title
+}
+
+// normalizeTitles writes back every title cleanTitle changes, leaving
+// manufacturers alone. A dry run only prints the changes, colored if asked.
+// With optimistic set, titles edited since they were read are skipped.
+func normalizeTitles(ctx context.Context, collection *mongo.Collection, aircraft []Aircraft, matcher Matcher, dryRun, optimistic, color bool, report *Report, out io.Writer) {
+	for _, a := range aircraft {
+		report.Processed++
+		title, removed := matcher.cleanTitle(a.Title)
+		if title == a.Title {
+			continue
+		}
+		report.TitlesChanged++
+		if dryRun {
+			before, after := titleDiff(a.Title, title, color)
+			fmt.Fprintf(out, "%s: %s -> %s\n", a.ID, before, after)
+			continue
+		}
+		fields := bson.M{"title": title}
+		if len(removed) > 0 {
+			fields["titleParentheticals"] = removed
+		}
+		filter := bson.M{"_id": a.ID}
+		if optimistic {
+			filter["title"] = a.Title
+		}
+		opCtx, cancel := opContext(ctx)
+		result, err := collection.UpdateOne(opCtx, filter, bson.M{"$set": fields})
+		cancel()
+		if err = acknowledged(err); err != nil {
+			report.Errors.add(a.ID, stageUpdate, err)
+			continue
+		}
+		if optimistic && result.MatchedCount == 0 {
+			fmt.Fprintf(out, "skipped %s: %v\n", a.ID, errStale)
+			report.Stale++
+			continue
+		}
+		report.Updated++
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCleanTitle(t *testing.T) {
+	tests := []struct {
+		m       Matcher
+		title   string
+		want    string
+		removed []string
+	}{
+		{Matcher{}, "  Boeing   737 ", "Boeing 737", nil},
+		{Matcher{StripPrefixes: []string{"The"}}, "The Boeing 737", "Boeing 737", nil},
+		{Matcher{StripParentheticals: true}, "Boeing (a subsidiary) 737", "Boeing 737", []string{"a subsidiary"}},
+		{Matcher{CleanPunctuation: true}, "- Boeing 737 ,, MAX ,", "Boeing 737, MAX", nil},
+		{Matcher{TitleCase: caseTitle}, "boeing 737 max", "Boeing 737 Max", nil},
+		{Matcher{TitleCase: caseTitle}, "airbus A320neo", "Airbus A320neo", nil},
+		{Matcher{TitleCase: caseUpper}, "Airbus a320neo", "AIRBUS A320NEO", nil},
+		{Matcher{TitleCase: caseLower}, "AIRBUS A320", "airbus a320", nil},
+		{Matcher{TitleCase: caseKeep}, "bOEING 737", "bOEING 737", nil},
+		// clauses and punctuation go before the length limit is applied
+		{Matcher{StripParentheticals: true, CleanPunctuation: true, MaxTitleLen: 11}, "(old) Boeing , 737 MAX", "Boeing, 737", []string{"old"}},
+		{Matcher{StripParentheticals: true, CleanPunctuation: true, TitleCase: caseTitle}, "boeing (retired) , 737", "Boeing, 737", []string{"retired"}},
+	}
+	for _, tt := range tests {
+		got, removed := tt.m.cleanTitle(tt.title)
+		if got != tt.want || !slices.Equal(removed, tt.removed) {
+			t.Errorf("cleanTitle(%q) = %q, %q, want %q, %q", tt.title, got, removed, tt.want, tt.removed)
+		}
+	}
+}
+
+func TestCleanPunctuation(t *testing.T) {
+	tests := []struct{ title, want string }{
+		{"737 , MAX", "737, MAX"},
+		{"737,, MAX", "737, MAX"},
+		{"737 , , MAX", "737, MAX"},
+		{"Wow!!", "Wow!"},
+		{"!!!", "!"},
+		{"Really?!", "Really?!"},
+		{"737-800", "737-800"},
+		{"/ 737 -", "737"},
+		{"Boeing Co.", "Boeing Co."},
+		{"A320 ; A321 ;", "A320; A321"},
+		{"- , -", "- , -"},
+	}
+	for _, tt := range tests {
+		if got := cleanPunctuation(tt.title); got != tt.want {
+			t.Errorf("cleanPunctuation(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestRecase(t *testing.T) {
+	tests := []struct{ title, mode, want string }{
+		{"de havilland canada dhc-6", caseTitle, "De Havilland Canada Dhc-6"},
+		{"école 1", caseTitle, "École 1"},
+		{"737 (abs)", caseTitle, "737 (abs)"},
+		{"École", caseUpper, "ÉCOLE"},
+		{"École", caseLower, "école"},
+		{"École", caseKeep, "École"},
+	}
+	for _, tt := range tests {
+		if got := recase(tt.title, tt.mode); got != tt.want {
+			t.Errorf("recase(%q, %s) = %q, want %q", tt.title, tt.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// loadOverrides reads a JSON object mapping aircraft IDs to the manufacturer
+// IDs they're assigned, whatever their titles say
+func loadOverrides(path string, manufacturers []Manufacturer) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	var overrides map[string]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, configErrorf("failed to parse overrides: %s: %w", path, err)
+	}
+	known := make(map[string]bool, len(manufacturers))
+	for _, m := range manufacturers {
+		known[m.ID] = true
+	}
+	for aircraftID, manufacturerID := range overrides {
+		if !known[manufacturerID] {
+			return nil, configErrorf("override for aircraft %s: unknown manufacturer %q", aircraftID, manufacturerID)
+		}
+	}
+
+	return overrides, nil
+}
+
+// resolveOverride assigns the aircraft the manufacturer it's overridden to.
+// The manufacturer's name is stripped if the title has it; otherwise the
+// title is left as it is.
+func (m Matcher) resolveOverride(a Aircraft, id string) Resolution {
+	title := m.normalize(a.Title)
+	window, _ := m.truncate(title)
+	masked := maskParentheticals(window)
+	for _, mf := range m.Manufacturers {
+		if mf.ID != id {
+			continue
+		}
+		for _, method := range defaultRules {
+			if c, ok := m.candidateBy(window, masked, mf, method); ok {
+				res := m.resolveAs(a, title, c)
+				res.Method = methodOverride
+				return res
+			}
+		}
+	}
+
+	return Resolution{ID: a.ID, OriginalTitle: a.Title, Title: a.Title, Manufacturer: id, Method: methodOverride}
+}
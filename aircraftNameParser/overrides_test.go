@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func overrideMatcher(t *testing.T) Matcher {
+	t.Helper()
+	return Matcher{
+		Manufacturers: testManufacturers(t, Manufacturer{ID: "airbus", Name: "Airbus"}, Manufacturer{ID: "boeing", Name: "Boeing"}),
+		Overrides:     map[string]string{"1": "airbus", "2": "boeing"},
+	}
+}
+
+func TestResolveOverride(t *testing.T) {
+	tests := []struct {
+		a     Aircraft
+		want  string
+		title string
+	}{
+		// the name isn't in the title, which is left alone
+		{Aircraft{ID: "1", Title: "Boeing  747"}, "airbus", "Boeing  747"},
+		{Aircraft{ID: "2", Title: "Airbus sold to Boeing 747"}, "boeing", "Airbus sold to 747"},
+		{Aircraft{ID: "3", Title: "Boeing 747"}, "boeing", "747"},
+	}
+	m := overrideMatcher(t)
+	for _, tt := range tests {
+		res := m.resolve(tt.a)
+		if res.Manufacturer != tt.want || res.Title != tt.title {
+			t.Errorf("resolve(%s %q) = %s %q, want %s %q", tt.a.ID, tt.a.Title, res.Manufacturer, res.Title, tt.want, tt.title)
+		}
+	}
+}
+
+func TestResolveInteractivelyAppliesOverrides(t *testing.T) {
+	m := overrideMatcher(t)
+	var out strings.Builder
+	res := m.resolveInteractively(Aircraft{ID: "1", Title: "Airbus Boeing"}, bufio.NewReader(strings.NewReader("2\n")), &out)
+	if res.Manufacturer != "airbus" || res.Method != methodOverride {
+		t.Errorf("resolved to %s by %s, want the airbus override", res.Manufacturer, res.Method)
+	}
+	if out.Len() > 0 {
+		t.Errorf("prompted for an overridden aircraft: %q", out.String())
+	}
+}
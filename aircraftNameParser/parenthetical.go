@@ -0,0 +1,71 @@
+package main
+
+import "strings"
+
+// parentheticalSpans returns the start and end of every outermost balanced
+// "(...)" clause in title, so nested clauses count as part of the one around
+// them. A "(" that is never closed and a stray ")" are left alone.
+func parentheticalSpans(title string) [][2]int {
+	var spans [][2]int
+	depth, open := 0, 0
+	for i := 0; i < len(title); i++ {
+		switch title[i] {
+		case '(':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case ')':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				spans = append(spans, [2]int{open, i + 1})
+			}
+		}
+	}
+
+	return spans
+}
+
+// maskParentheticals blanks out the parenthetical clauses of title with
+// spaces, keeping every other byte where it was, so "Airbus (a subsidiary of
+// Boeing) A320" is only matched as Airbus
+func maskParentheticals(title string) string {
+	spans := parentheticalSpans(title)
+	if len(spans) == 0 {
+		return title
+	}
+	masked := []byte(title)
+	for _, span := range spans {
+		for i := span[0]; i < span[1]; i++ {
+			masked[i] = ' '
+		}
+	}
+
+	return string(masked)
+}
+
+// removeParentheticals cuts the parenthetical clauses out of title and
+// returns what they said, without the outer parentheses
+func removeParentheticals(title string) (string, []string) {
+	spans := parentheticalSpans(title)
+	if len(spans) == 0 {
+		return title, nil
+	}
+	var kept strings.Builder
+	var removed []string
+	last := 0
+	for _, span := range spans {
+		kept.WriteString(title[last:span[0]])
+		kept.WriteByte(' ')
+		if inner := strings.TrimSpace(title[span[0]+1 : span[1]-1]); inner != "" {
+			removed = append(removed, inner)
+		}
+		last = span[1]
+	}
+	kept.WriteString(title[last:])
+
+	return kept.String(), removed
+}
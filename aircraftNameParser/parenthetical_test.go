@@ -0,0 +1,87 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParentheticalSpans(t *testing.T) {
+	tests := []struct {
+		title string
+		want  [][2]int
+	}{
+		{"Boeing 737", nil},
+		{"Boeing (a subsidiary) 737", [][2]int{{7, 21}}},
+		// nested clauses are part of the one around them
+		{"Boeing (a (b) c) 737", [][2]int{{7, 16}}},
+		{"(a) Boeing (b)", [][2]int{{0, 3}, {11, 14}}},
+		// unbalanced parentheses are left alone
+		{"Boeing (a subsidiary", nil},
+		{"Boeing a) 737", nil},
+		{"Boeing a) (b) 737", [][2]int{{10, 13}}},
+		{"Boeing ((a) 737", nil},
+	}
+	for _, tt := range tests {
+		if got := parentheticalSpans(tt.title); !slices.Equal(got, tt.want) {
+			t.Errorf("parentheticalSpans(%q) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestMaskParentheticals(t *testing.T) {
+	tests := []struct{ title, want string }{
+		{"Airbus (a subsidiary of Boeing) A320", "Airbus " + strings.Repeat(" ", len("(a subsidiary of Boeing)")) + " A320"},
+		{"Airbus (a (b) Boeing) A320", "Airbus " + strings.Repeat(" ", len("(a (b) Boeing)")) + " A320"},
+		{"Airbus (Boeing A320", "Airbus (Boeing A320"},
+	}
+	for _, tt := range tests {
+		if got := maskParentheticals(tt.title); got != tt.want {
+			t.Errorf("maskParentheticals(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveParentheticals(t *testing.T) {
+	tests := []struct {
+		title   string
+		want    string
+		removed []string
+	}{
+		{"737 (a subsidiary of ...) MAX", "737   MAX", []string{"a subsidiary of ..."}},
+		{"737 (a (b) c)", "737  ", []string{"a (b) c"}},
+		{"737 ( ) MAX", "737   MAX", nil},
+		{"737 (unclosed", "737 (unclosed", nil},
+		{"737) MAX", "737) MAX", nil},
+	}
+	for _, tt := range tests {
+		got, removed := removeParentheticals(tt.title)
+		if got != tt.want || !slices.Equal(removed, tt.removed) {
+			t.Errorf("removeParentheticals(%q) = %q, %q, want %q, %q", tt.title, got, removed, tt.want, tt.removed)
+		}
+	}
+}
+
+func TestResolveIgnoresParentheticals(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t, Manufacturer{ID: "airbus", Name: "Airbus"}, Manufacturer{ID: "boeing", Name: "Boeing"})}
+	tests := []struct {
+		title        string
+		strip        bool
+		manufacturer string
+		want         string
+		removed      []string
+	}{
+		{"Boeing (a subsidiary of (Airbus)) 737", false, "boeing", "(a subsidiary of (Airbus)) 737", nil},
+		{"Boeing (a subsidiary of (Airbus)) 737", true, "boeing", "737", []string{"a subsidiary of (Airbus)"}},
+		{"(Airbus) Boeing 737", false, "boeing", "(Airbus) 737", nil},
+		// an unclosed parenthesis hides nothing
+		{"(Airbus Boeing 737", true, "airbus", "( Boeing 737", nil},
+	}
+	for _, tt := range tests {
+		m.StripParentheticals = tt.strip
+		res := m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Manufacturer != tt.manufacturer || res.Title != tt.want || !slices.Equal(res.Parentheticals, tt.removed) {
+			t.Errorf("resolve(%q) with strip %v = %s %q %q, want %s %q %q", tt.title, tt.strip, res.Manufacturer, res.Title, res.Parentheticals, tt.manufacturer, tt.want, tt.removed)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import "strings"
+
+// metaphone returns the Metaphone key of word, which is the same for words
+// that sound alike in English, e.g. "Serrus" and "Cirrus" are both "SRS".
+// Anything but ASCII letters is ignored.
+func metaphone(word string) string {
+	var letters []byte
+	for i := 0; i < len(word); i++ {
+		b := word[i]
+		if b >= 'a' && b <= 'z' {
+			b -= 'a' - 'A'
+		}
+		if b >= 'A' && b <= 'Z' {
+			letters = append(letters, b)
+		}
+	}
+	w := string(letters)
+	switch {
+	case w == "":
+		return ""
+	case strings.HasPrefix(w, "KN"), strings.HasPrefix(w, "GN"), strings.HasPrefix(w, "PN"), strings.HasPrefix(w, "AE"), strings.HasPrefix(w, "WR"):
+		w = w[1:]
+	case w[0] == 'X':
+		w = "S" + w[1:]
+	case strings.HasPrefix(w, "WH"):
+		w = "W" + w[2:]
+	}
+
+	at := func(i int) byte {
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+		return w[i]
+	}
+	isVowel := func(b byte) bool {
+		return strings.IndexByte("AEIOU", b) >= 0 && b != 0
+	}
+	frontVowel := func(b byte) bool {
+		return b == 'E' || b == 'I' || b == 'Y'
+	}
+
+	var key strings.Builder
+	for i := 0; i < len(w); i++ {
+		c := w[i]
+		if c == at(i-1) && c != 'C' {
+			continue
+		}
+		next := at(i + 1)
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				key.WriteByte(c)
+			}
+		case 'B':
+			if !(i == len(w)-1 && at(i-1) == 'M') {
+				key.WriteByte('B')
+			}
+		case 'C':
+			switch {
+			case next == 'I' && at(i+2) == 'A', next == 'H' && at(i-1) != 'S':
+				key.WriteByte('X')
+			case frontVowel(next):
+				if at(i-1) != 'S' {
+					key.WriteByte('S')
+				}
+			default:
+				key.WriteByte('K')
+			}
+		case 'D':
+			if next == 'G' && frontVowel(at(i+2)) {
+				key.WriteByte('J')
+			} else {
+				key.WriteByte('T')
+			}
+		case 'G':
+			switch {
+			case next == 'H' && i+2 < len(w) && !isVowel(at(i+2)):
+			case next == 'N' && (i+2 == len(w) || (at(i+2) == 'E' && at(i+3) == 'D' && i+4 == len(w))):
+			case frontVowel(next) && at(i-1) != 'G':
+				key.WriteByte('J')
+			default:
+				key.WriteByte('K')
+			}
+		case 'H':
+			if isVowel(next) && strings.IndexByte("CSPTG", at(i-1)) < 0 {
+				key.WriteByte('H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				key.WriteByte('K')
+			}
+		case 'P':
+			if next == 'H' {
+				key.WriteByte('F')
+			} else {
+				key.WriteByte('P')
+			}
+		case 'Q':
+			key.WriteByte('K')
+		case 'S':
+			if next == 'H' || (next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A')) {
+				key.WriteByte('X')
+			} else {
+				key.WriteByte('S')
+			}
+		case 'T':
+			switch {
+			case next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				key.WriteByte('X')
+			case next == 'H':
+				key.WriteByte('0')
+			case next == 'C' && at(i+2) == 'H':
+			default:
+				key.WriteByte('T')
+			}
+		case 'V':
+			key.WriteByte('F')
+		case 'W', 'Y':
+			if isVowel(next) {
+				key.WriteByte(c)
+			}
+		case 'X':
+			key.WriteString("KS")
+		case 'Z':
+			key.WriteByte('S')
+		default:
+			key.WriteByte(c)
+		}
+	}
+
+	return key.String()
+}
+
+// minPhoneticKey is the shortest key worth comparing; shorter ones match far
+// too many unrelated words
+const minPhoneticKey = 3
+
+// findPhonetic returns the span of the first run of words in title that
+// sounds like name, word for word
+func findPhonetic(title, name string) (int, int, bool) {
+	var want []string
+	for _, word := range strings.Fields(name) {
+		want = append(want, metaphone(word))
+	}
+	if len(want) == 0 || len(strings.Join(want, "")) < minPhoneticKey {
+		return 0, 0, false
+	}
+
+	spans := wordSpans(title)
+	for i := 0; i+len(want) <= len(spans); i++ {
+		matched := true
+		for j, key := range want {
+			span := spans[i+j]
+			if metaphone(title[span[0]:span[1]]) != key {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return spans[i][0], spans[i+len(want)-1][1], true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// wordSpans returns the [start, end) offsets of the space-separated words
+// of title
+func wordSpans(title string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i := 0; i <= len(title); i++ {
+		if i == len(title) || title[i] == ' ' {
+			if start >= 0 {
+				spans = append(spans, [2]int{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+
+	return spans
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestMetaphone(t *testing.T) {
+	alike := [][2]string{
+		{"Serrus", "Cirrus"},
+		{"Sessna", "Cessna"},
+		{"Beachcraft", "Beechcraft"},
+		{"Grumann", "Grumman"},
+		{"Embrair", "Embraer"},
+		{"cirrus", "CIRRUS"},
+	}
+	for _, pair := range alike {
+		if a, b := metaphone(pair[0]), metaphone(pair[1]); a != b {
+			t.Errorf("metaphone(%q) = %q, metaphone(%q) = %q, want them equal", pair[0], a, pair[1], b)
+		}
+	}
+	different := [][2]string{
+		{"Bowing", "Boeing"},
+		{"Erbus", "Airbus"},
+		{"Cirrus", "Cessna"},
+	}
+	for _, pair := range different {
+		if a := metaphone(pair[0]); a == metaphone(pair[1]) {
+			t.Errorf("metaphone(%q) = metaphone(%q) = %q, want them to differ", pair[0], pair[1], a)
+		}
+	}
+	if got := metaphone("737-800"); got != "" {
+		t.Errorf("metaphone(%q) = %q, want no key", "737-800", got)
+	}
+}
+
+func TestFindPhonetic(t *testing.T) {
+	tests := []struct {
+		title, name string
+		start, end  int
+		ok          bool
+	}{
+		{"Serrus SR22", "Cirrus", 0, 6, true},
+		{"SR22 by Serrus", "Cirrus", 8, 14, true},
+		{"Sessna  172", "Cessna", 0, 6, true},
+		{"Grumann Gulfstream II", "Grumman Gulfstream", 0, 18, true},
+		// every word of the name has to sound alike
+		{"Grumann Goose", "Grumman Gulfstream", 0, 0, false},
+		// keys this short match too much
+		{"Muni M20", "Mooney", 0, 0, false},
+		{"SR22", "Cirrus", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, ok := findPhonetic(tt.title, tt.name)
+		if start != tt.start || end != tt.end || ok != tt.ok {
+			t.Errorf("findPhonetic(%q, %q) = %d, %d, %v, want %d, %d, %v", tt.title, tt.name, start, end, ok, tt.start, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestResolvePhoneticIsLastResort(t *testing.T) {
+	manufacturers := testManufacturers(t, Manufacturer{ID: "cirrus", Name: "Cirrus"}, Manufacturer{ID: "cessna", Name: "Cessna"})
+	m := Matcher{Manufacturers: manufacturers}
+	if res := m.resolve(Aircraft{ID: "1", Title: "Serrus SR22"}); res.Matched() {
+		t.Errorf("matched %s phonetically without -phonetic", res.Manufacturer)
+	}
+	m.Phonetic = true
+	tests := []struct {
+		title        string
+		manufacturer string
+		want         string
+		method       string
+	}{
+		{"Serrus SR22", "cirrus", "SR22", methodPhonetic},
+		// an exact match anywhere wins
+		{"Serrus SR22 Cessna", "cessna", "Serrus SR22", methodExact},
+	}
+	for _, tt := range tests {
+		res := m.resolve(Aircraft{ID: "1", Title: tt.title})
+		if res.Manufacturer != tt.manufacturer || res.Title != tt.want || res.Method != tt.method {
+			t.Errorf("resolve(%q) = %s %q by %q, want %s %q by %q", tt.title, res.Manufacturer, res.Title, res.Method, tt.manufacturer, tt.want, tt.method)
+		}
+	}
+}
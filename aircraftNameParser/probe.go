@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// sampleAircraft fetches n random aircraft matching filter with $sample,
+// recording documents that fail to decode in errs like getAircrafts
+func sampleAircraft(ctx context.Context, db *mongo.Database, readPreference *readpref.ReadPref, filter bson.M, n int, errs *ErrorLog) ([]Aircraft, error) {
+	collection := db.Collection("aircraft", mongoOptions.Collection().SetReadPreference(readPreference))
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sample", Value: bson.M{"size": n}}},
+	}
+	opCtx, cancel := opContext(ctx)
+	cursor, err := collection.Aggregate(opCtx, pipeline)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var aircraft []Aircraft
+	for nextDoc(ctx, cursor) {
+		var a Aircraft
+		if err := cursor.Decode(&a); err != nil {
+			id, _ := cursor.Current.Lookup("_id").StringValueOK()
+			errs.add(id, stageDecode, err)
+			continue
+		}
+		aircraft = append(aircraft, a)
+	}
+
+	return aircraft, cursor.Err()
+}
+
+// probe prints how every aircraft would be resolved, without writing
+func probe(aircraft []Aircraft, matcher Matcher, color bool, report *Report, out io.Writer) {
+	for _, a := range aircraft {
+		report.Processed++
+		res := matcher.resolve(a)
+		if res.AlreadyClean {
+			report.AlreadyClean++
+			fmt.Fprintf(out, "%s: %q -> already clean\n", a.ID, a.Title)
+			continue
+		}
+		if !res.Matched() {
+			fmt.Fprintf(out, "%s: %q -> no match\n", a.ID, a.Title)
+			continue
+		}
+		report.countMatch(res.Manufacturer)
+		before, after := titleDiff(a.Title, res.Title, color)
+		fmt.Fprintf(out, "%s: %s -> %s (%s) %s\n", a.ID, before, res.Manufacturer, res.Method, after)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// processor resolves aircraft one at a time, writes the results and keeps
+// the run's report
+type processor struct {
+	cfg     Config
+	writer  *writer
+	matcher Matcher
+	errs    *ErrorLog
+	report  *Report
+
+	out    io.Writer
+	stream *json.Encoder // Per-aircraft output, nil when not streaming
+	dump   *bsonDump     // Resolved documents, nil without -bson-dump
+	stdin  *bufio.Reader
+
+	// Set once -max-updates stopped the run, nothing more is processed
+	halted error
+	// Updates queued in the writer's batch
+	pending int
+	// ICAO type designator -> manufacturer ID, to cross-check matches
+	icaoManufacturers map[string]string
+}
+
+func newProcessor(cfg Config, w *writer, matcher Matcher, report *Report) *processor {
+	p := &processor{
+		cfg:     cfg,
+		writer:  w,
+		matcher: matcher,
+		errs:    report.Errors,
+		report:  report,
+		out:     cfg.messages(),
+		stdin:   bufio.NewReader(cfg.Stdin),
+	}
+	if cfg.Output == outputNDJSON {
+		p.stream = json.NewEncoder(cfg.Stdout)
+	}
+
+	return p
+}
+
+// plannedUpdates counts the aircraft process would write, without writing
+// or prompting
+func (p *processor) plannedUpdates(aircraft []Aircraft) int {
+	n := 0
+	for _, a := range aircraft {
+		res := p.matcher.resolve(a)
+		if res.AlreadyClean || !res.Matched() || p.strictSkip(a, res) {
+			continue
+		}
+		n++
+	}
+
+	return n
+}
+
+// strictSkip reports whether -strict keeps the resolution from being written
+func (p *processor) strictSkip(a Aircraft, res Resolution) bool {
+	if !p.cfg.Strict {
+		return false
+	}
+	_, disagrees := p.icaoDisagreement(a, res)
+
+	return res.LostCode != "" || disagrees
+}
+
+// outcome is the aircraft as a run would leave it, without writing or
+// prompting
+func (p *processor) outcome(a Aircraft) Aircraft {
+	res := p.matcher.resolve(a)
+	if res.AlreadyClean || !res.Matched() || p.strictSkip(a, res) {
+		return a
+	}
+	a.Manufacturer, a.Title = res.Manufacturer, res.Title
+
+	return a
+}
+
+// process resolves one aircraft and writes the result, reporting whether it
+// was written
+func (p *processor) process(ctx context.Context, a Aircraft) (Resolution, bool) {
+	p.report.Processed++
+	var res Resolution
+	if p.cfg.Interactive {
+		res = p.matcher.resolveInteractively(a, p.stdin, p.out)
+	} else {
+		res = p.matcher.resolve(a)
+	}
+	if p.stream != nil {
+		if err := p.stream.Encode(res); err != nil {
+			p.errs.add(a.ID, stageOutput, err)
+		}
+	}
+	if p.cfg.LengthHistogram {
+		p.report.LengthsBefore.add(a.Title)
+		p.report.LengthsAfter.add(res.Title)
+	}
+	if res.AlreadyClean {
+		// Left as is, so re-runs don't strip the manufacturer again
+		p.report.AlreadyClean++
+		return res, false
+	}
+	if !res.Matched() {
+		// the stream lists them already, and it's meant to run without
+		// holding on to every aircraft
+		if p.stream == nil {
+			p.report.Unmatched = append(p.report.Unmatched, a.Title)
+		}
+		return res, false
+	}
+	p.report.countMatch(res.Manufacturer)
+	if res.Method == methodPhonetic {
+		fmt.Fprintf(p.out, "low-confidence phonetic match for %s: %q -> %s\n", a.ID, a.Title, res.Manufacturer)
+	}
+	if res.Truncated {
+		p.report.Truncated++
+	}
+	if res.LostCode != "" {
+		fmt.Fprintf(p.out, "suspicious strip from %s: %q no longer contains %q\n", a.ID, a.Title, res.LostCode)
+		p.report.Suspicious++
+		if p.cfg.Strict {
+			return res, false
+		}
+	}
+	if expected, ok := p.icaoDisagreement(a, res); ok {
+		fmt.Fprintf(p.out, "ICAO disagreement for %s: %q matched %s, but %s is built by %s\n", a.ID, a.Title, res.Manufacturer, a.Icao, expected)
+		p.report.IcaoDisagreements++
+		if p.cfg.Strict {
+			return res, false
+		}
+	}
+	if p.dump != nil {
+		if err := p.dump.write(p.writer, a, res); err != nil {
+			p.errs.add(a.ID, stageOutput, err)
+		}
+	}
+	if p.cfg.DryRun {
+		if p.cfg.ReportHTML != "" && !res.sameAs(a) {
+			p.report.Proposed = append(p.report.Proposed, res)
+		}
+		return res, false
+	}
+	if p.cfg.MaxUpdates > 0 && p.report.Updated+p.pending >= p.cfg.MaxUpdates {
+		p.halted = fmt.Errorf("stopped at %s: already updated %d aircraft, the -max-updates limit", a.ID, p.report.Updated+p.pending)
+		return res, false
+	}
+	err := p.writer.write(ctx, a, res)
+	if errors.Is(err, errStale) {
+		fmt.Fprintf(p.out, "skipped %s: %v\n", a.ID, err)
+		p.report.Stale++
+		return res, false
+	}
+	if errors.Is(err, errKeyConflict) {
+		fmt.Fprintf(p.out, "conflict writing %s: %v\n", a.ID, err)
+		p.report.Conflicts++
+		return res, false
+	}
+	if err != nil {
+		p.errs.add(a.ID, stageUpdate, err)
+		return res, false
+	}
+	if p.writer.batch != nil {
+		// counted once the batch is written
+		p.pending++
+		if p.writer.batch.full() {
+			p.flush(ctx)
+		}
+		return res, false
+	}
+	p.report.Updated++
+
+	return res, true
+}
+
+// flush writes any batched updates
+func (p *processor) flush(ctx context.Context) {
+	if p.writer.batch == nil {
+		return
+	}
+	written, failed := p.writer.batch.flush(ctx, p.writer.collection)
+	p.report.Updated += written
+	for id, err := range failed {
+		p.errs.add(id, stageUpdate, err)
+	}
+	p.pending = 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProcessStreamsUnmatched(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t, Manufacturer{ID: "airbus", Name: "Airbus"})}
+	var stream strings.Builder
+	report := &Report{Errors: newErrorLog(0)}
+	p := &processor{cfg: Config{DryRun: true, Output: outputNDJSON}, matcher: m, errs: report.Errors, report: report, out: io.Discard, stream: json.NewEncoder(&stream)}
+	for _, title := range []string{"Airbus A320", "Cessna 172", "Piper Cub"} {
+		p.process(context.Background(), Aircraft{ID: title, Title: title})
+	}
+	if report.Processed != 3 || report.Matched != 1 {
+		t.Errorf("processed %d, matched %d, want 3 and 1", report.Processed, report.Matched)
+	}
+	if len(report.Unmatched) != 0 {
+		t.Errorf("kept %d unmatched titles while streaming", len(report.Unmatched))
+	}
+	if n := strings.Count(stream.String(), "\n"); n != 3 {
+		t.Errorf("streamed %d records, want 3", n)
+	}
+
+	p.stream = nil
+	p.process(context.Background(), Aircraft{ID: "4", Title: "Cessna 172"})
+	if len(report.Unmatched) != 1 {
+		t.Errorf("kept %d unmatched titles without streaming, want 1", len(report.Unmatched))
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"time"
+)
+
+type Report struct {
+	Mode       string `json:"mode"`       // What the run did, see Config.mode
+	RunID      string `json:"runId"`      // Identifies the run's changes in the audit collection
+	Processed  int    `json:"processed"`  // Aircraft looked at
+	Matched    int    `json:"matched"`    // Aircraft a manufacturer was found for
+	Updated    int    `json:"updated"`    // Aircraft written back to the collection
+	Suspicious int    `json:"suspicious"` // Strips that removed the aircraft's own ICAO/IATA code
+	Conflicts  int    `json:"conflicts"`  // Aircraft not upserted because another one already had their key
+	Stale      int    `json:"stale"`      // Aircraft not updated because their title changed after it was read
+	Truncated  int    `json:"truncated"`  // Matched aircraft whose title was cut to -max-title-length
+	Mismatches int    `json:"mismatches"` // Aircraft differing from the -compare-collection reference
+	Changed    int    `json:"changed"`    // Aircraft whose result differs from the -baseline, or between the -compare-rules
+	Dangling   int    `json:"dangling"`   // Aircraft referring to a manufacturer that isn't known
+	Ambiguous  int    `json:"ambiguous"`  // Aircraft matching both manufacturers of a -collision-report pair
+	// Matches the -icao-manufacturers mapping credits to another manufacturer
+	IcaoDisagreements int `json:"icaoDisagreements"`
+	// Titles -normalize-only cleaned up
+	TitlesChanged int `json:"titlesChanged"`
+	// Aircraft skipped because their title already starts with the
+	// canonical name of the manufacturer they refer to
+	AlreadyClean int `json:"alreadyClean"`
+
+	// Matches per manufacturer ID. A short name claiming far more aircraft
+	// than expected usually means it's matching inside other words.
+	ByManufacturer map[string]int `json:"byManufacturer"`
+	// Titles of the processed aircraft no manufacturer was found for, not
+	// kept with -output=ndjson, whose records show them
+	Unmatched []string `json:"unmatchedTitles"`
+	// Changes a dry run would have made, only kept for -report-html
+	Proposed []Resolution `json:"-"`
+
+	// Title lengths as read and as they'd be written, only with -length-histogram
+	LengthsBefore LengthHistogram `json:"lengthsBefore,omitempty"`
+	LengthsAfter  LengthHistogram `json:"lengthsAfter,omitempty"`
+
+	Errors *ErrorLog `json:"errors"`
+
+	Elapsed  time.Duration `json:"elapsedNs"` // Wall-clock time of the whole run
+	MemoryOS uint64        `json:"memoryOs"`  // Bytes obtained from the OS, the high-water mark of the heap and runtime; only set with -stats
+}
+
+func (r *Report) countMatch(manufacturerID string) {
+	r.Matched++
+	if r.ByManufacturer == nil {
+		r.ByManufacturer = make(map[string]int)
+	}
+	r.ByManufacturer[manufacturerID]++
+}
+
+// finish records the run's duration and, if asked, its memory use
+func (r *Report) finish(start time.Time, stats bool) {
+	r.Elapsed = time.Since(start)
+	if stats {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		r.MemoryOS = mem.Sys
+	}
+}
+
+// WriteJSON writes the report to path as a single JSON object, for tools
+// that check a run's results
+func (r Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func (r Report) Print(w io.Writer) {
+	switch r.Mode {
+	case modeCompare:
+		fmt.Fprintf(w, "compared: %d, mismatches: %d\n", r.Processed, r.Mismatches)
+	case modeBaseline:
+		fmt.Fprintf(w, "compared: %d, changed since baseline: %d\n", r.Processed, r.Changed)
+	case modeRules:
+		fmt.Fprintf(w, "compared: %d, resolved differently: %d\n", r.Processed, r.Changed)
+	case modeProbe:
+		fmt.Fprintf(w, "sampled: %d, matched: %d\n", r.Processed, r.Matched)
+	case modeCollision:
+		fmt.Fprintf(w, "checked: %d, aircraft matching a colliding pair: %d\n", r.Processed, r.Ambiguous)
+	case modeVerify:
+		fmt.Fprintf(w, "checked: %d, unknown manufacturer references: %d\n", r.Processed, r.Dangling)
+	case modeFixtures:
+		fmt.Fprintf(w, "fixtures generated: %d\n", r.Processed)
+	case modeNormalize:
+		fmt.Fprintf(w, "processed: %d, titles changed: %d, updated: %d\n", r.Processed, r.TitlesChanged, r.Updated)
+	case modeRevert:
+		fmt.Fprintf(w, "aircraft to revert: %d, reverted: %d\n", r.Processed, r.Updated)
+	case modeDiscover:
+	default:
+		fmt.Fprintf(w, "run %s\n", r.RunID)
+		fmt.Fprintf(w, "processed: %d, matched: %d, updated: %d, suspicious: %d\n", r.Processed, r.Matched, r.Updated, r.Suspicious)
+	}
+	if r.AlreadyClean > 0 {
+		fmt.Fprintf(w, "already clean: %d\n", r.AlreadyClean)
+	}
+	if r.Truncated > 0 {
+		fmt.Fprintf(w, "titles truncated: %d\n", r.Truncated)
+	}
+	if r.IcaoDisagreements > 0 {
+		fmt.Fprintf(w, "ICAO disagreements: %d\n", r.IcaoDisagreements)
+	}
+	if r.Stale > 0 {
+		fmt.Fprintf(w, "skipped, edited concurrently: %d\n", r.Stale)
+	}
+	if r.Conflicts > 0 {
+		fmt.Fprintf(w, "key conflicts: %d\n", r.Conflicts)
+	}
+	if len(r.ByManufacturer) > 0 {
+		fmt.Fprintln(w, "matches by manufacturer:")
+		ids := slices.Collect(maps.Keys(r.ByManufacturer))
+		slices.SortFunc(ids, func(a, b string) int {
+			return cmp.Or(cmp.Compare(r.ByManufacturer[b], r.ByManufacturer[a]), cmp.Compare(a, b))
+		})
+		for _, id := range ids {
+			fmt.Fprintf(w, "%8d  %s\n", r.ByManufacturer[id], id)
+		}
+	}
+	if len(r.LengthsBefore) > 0 {
+		printHistograms(w, r.LengthsBefore, r.LengthsAfter)
+	}
+	if seconds := r.Elapsed.Seconds(); seconds > 0 {
+		fmt.Fprintf(w, "took %s: %.1f aircraft/s, %.1f updates/s\n", r.Elapsed.Round(time.Millisecond), float64(r.Processed)/seconds, float64(r.Updated)/seconds)
+	}
+	if r.Errors != nil {
+		r.Errors.Print(w)
+	}
+	if r.MemoryOS > 0 {
+		fmt.Fprintf(w, "memory: %.1f MiB obtained from the OS\n", float64(r.MemoryOS)/(1<<20))
+	}
+}
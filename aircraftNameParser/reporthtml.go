@@ -0,0 +1,86 @@
+package main
+
+import (
+	"cmp"
+	"html/template"
+	"os"
+	"slices"
+)
+
+type htmlGroup struct {
+	Manufacturer string
+	Changes      []Resolution
+}
+
+// WriteHTML writes the summary counts and a dry run's proposed changes,
+// grouped by manufacturer, as one self-contained page for sign-off before
+// the real run
+func (r Report) WriteHTML(path string) error {
+	byManufacturer := make(map[string][]Resolution)
+	for _, res := range r.Proposed {
+		byManufacturer[res.Manufacturer] = append(byManufacturer[res.Manufacturer], res)
+	}
+	var groups []htmlGroup
+	for id, changes := range byManufacturer {
+		groups = append(groups, htmlGroup{Manufacturer: id, Changes: changes})
+	}
+	slices.SortFunc(groups, func(a, b htmlGroup) int {
+		return cmp.Or(cmp.Compare(len(b.Changes), len(a.Changes)), cmp.Compare(a.Manufacturer, b.Manufacturer))
+	})
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = htmlReport.Execute(file, struct {
+		Report Report
+		Groups []htmlGroup
+	}{r, groups})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Proposed manufacturer changes</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; cursor: pointer; }
+.warning { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Proposed manufacturer changes</h1>
+<p>Processed: {{.Report.Processed}}, matched: {{.Report.Matched}}, to change: {{len .Report.Proposed}}, suspicious: {{.Report.Suspicious}}, truncated: {{.Report.Truncated}}</p>
+{{range .Groups}}
+<h2>{{.Manufacturer}} ({{len .Changes}})</h2>
+<table class="sortable">
+<thead><tr><th>Aircraft</th><th>Current title</th><th>New title</th><th>Method</th><th>Notes</th></tr></thead>
+<tbody>
+{{range .Changes}}<tr><td>{{.ID}}</td><td>{{.OriginalTitle}}</td><td>{{.Title}}</td><td>{{.Method}}</td><td>{{if .LostCode}}<span class="warning">drops {{.LostCode}}</span>{{end}}{{if .Truncated}} truncated{{end}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+<script>
+document.querySelectorAll("table.sortable th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var body = th.closest("table").tBodies[0], column = th.cellIndex;
+    var ascending = th.dataset.order !== "asc";
+    th.dataset.order = ascending ? "asc" : "desc";
+    Array.from(body.rows).sort(function (a, b) {
+      var x = a.cells[column].textContent, y = b.cells[column].textContent;
+      return ascending ? x.localeCompare(y) : y.localeCompare(x);
+    }).forEach(function (row) { body.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// compareRules resolves every aircraft with both matchers and prints those
+// they disagree on, then how many each one matched, to show what switching
+// rule sets would change. Nothing is written. It returns the number of
+// aircraft printed.
+func compareRules(aircraft []Aircraft, a, b Matcher, color bool, out io.Writer) int {
+	nameA, nameB := strings.Join(a.Rules, ","), strings.Join(b.Rules, ",")
+	differing, matchedA, matchedB, onlyA, onlyB := 0, 0, 0, 0, 0
+	for _, ac := range aircraft {
+		resA, resB := a.resolve(ac), b.resolve(ac)
+		if resA.Matched() {
+			matchedA++
+		}
+		if resB.Matched() {
+			matchedB++
+		}
+		if resA.Manufacturer == resB.Manufacturer && resA.Title == resB.Title {
+			continue
+		}
+		switch {
+		case !resB.Matched():
+			onlyA++
+		case !resA.Matched():
+			onlyB++
+		}
+		differing++
+		titleA, titleB := titleDiff(resA.Title, resB.Title, color)
+		fmt.Fprintf(out, "%s: %q\n  %s: %s\n  %s: %s\n", ac.ID, ac.Title, nameA, describe(resA, titleA), nameB, describe(resB, titleB))
+	}
+	fmt.Fprintf(out, "%s matched %d, %s matched %d; only %s matched %d, only %s matched %d\n", nameA, matchedA, nameB, matchedB, nameA, onlyA, nameB, onlyB)
+
+	return differing
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Run connects to cfg.MongoURI, loads the manufacturers and aircraft and
+// processes them as cfg asks, returning the run's summary. Read-only modes
+// write their findings to cfg.messages() as they go. Nothing is read from
+// the environment or the process's standard streams that cfg doesn't name,
+// so Run can be called with a Config built by hand.
+func Run(ctx context.Context, cfg Config) (report Report, err error) {
+	start := time.Now()
+	ctx = withOpTimeout(ctx, cfg.OpTimeout)
+	report = Report{Mode: cfg.mode(), RunID: primitive.NewObjectID().Hex(), Errors: newErrorLog(cfg.MaxErrorDetails)}
+	defer func() {
+		report.finish(start, cfg.Stats)
+	}()
+	if err := cfg.prepare(); err != nil {
+		return report, err
+	}
+
+	manufacturers, err := loadManufacturers(cfg.Manufacturers, cfg.UseEmbedded, cfg.LenientManufacturers)
+	if err != nil {
+		return report, err
+	}
+	if cfg.ManufacturerAsObjectID {
+		for _, m := range manufacturers {
+			if !primitive.IsValidObjectID(m.ID) {
+				return report, configErrorf("manufacturer %q has ID %q, which is not an ObjectID", m.Name, m.ID)
+			}
+		}
+	}
+	var fixtures []fixture
+	if report.Mode == modeFixtures {
+		if len(manufacturers) == 0 {
+			return report, configErrorf("no manufacturers to generate fixtures from")
+		}
+		fixtures = generateFixtures(manufacturers, cfg.GenerateFixtures, cfg.FixturesNoise, cfg.FixturesSeed)
+		report.Processed = len(fixtures)
+		if cfg.FixturesFile != "" {
+			return report, writeFixturesFile(cfg.FixturesFile, fixtures)
+		}
+	}
+	var baseline map[string]Resolution
+	if cfg.Baseline != "" {
+		baseline, err = loadBaseline(cfg.Baseline)
+		if err != nil {
+			return report, err
+		}
+	}
+	var icaoManufacturers map[string]string
+	if cfg.ICAOManufacturers != "" {
+		icaoManufacturers, err = loadICAOManufacturers(cfg.ICAOManufacturers)
+		if err != nil {
+			return report, err
+		}
+	}
+	var overrides map[string]string
+	if cfg.Overrides != "" {
+		overrides, err = loadOverrides(cfg.Overrides, manufacturers)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	mongoDB, err := connectToMongo(ctx, cfg.MongoURI, cfg.Database, cfg.ConnectRetries)
+	if err != nil {
+		return report, err
+	}
+	defer mongoDB.Client().Disconnect(context.Background())
+	writeOpts := mongoOptions.Collection().SetWriteConcern(cfg.writeConcern)
+	if report.Mode == modeFixtures {
+		return report, insertFixtures(ctx, mongoDB.Collection(cfg.FixturesCollection, writeOpts), fixtures)
+	}
+	if report.Mode == modeRevert {
+		return report, revertRun(ctx, mongoDB.Collection("aircraft", writeOpts), mongoDB.Collection(cfg.AuditCollection), cfg.RevertRun, &report)
+	}
+
+	matcher := Matcher{
+		Manufacturers:       manufacturers,
+		StripPrefixes:       cfg.StripPrefixes,
+		Mode:                cfg.MatchMode,
+		Connectors:          cfg.Connectors,
+		Canonical:           cfg.CanonicalTitle,
+		MaxTitleLen:         cfg.MaxTitleLength,
+		Phonetic:            cfg.Phonetic,
+		Rules:               cfg.MatchRules,
+		StripParentheticals: cfg.StripParentheticals,
+		TitleCase:           cfg.TitleCase,
+		CleanPunctuation:    cfg.CleanPunctuation,
+		Overrides:           overrides,
+	}
+	collection := mongoDB.Collection(cfg.target(), writeOpts)
+	w := newWriter(collection, cfg)
+	if cfg.AuditCollection != "" {
+		w.audit = mongoDB.Collection(cfg.AuditCollection, writeOpts)
+		w.runID = report.RunID
+	}
+	if cfg.AuditFile != "" {
+		w.auditFile, err = openAuditFile(cfg.AuditFile)
+		if err != nil {
+			return report, err
+		}
+		defer w.auditFile.Close()
+		w.runID = report.RunID
+	}
+	p := newProcessor(cfg, w, matcher, &report)
+	p.icaoManufacturers = icaoManufacturers
+	if cfg.BSONDump != "" {
+		p.dump, err = newBSONDump(cfg.BSONDump, cfg.Stdout)
+		if err != nil {
+			return report, err
+		}
+		defer func() {
+			if cerr := p.dump.Close(); err == nil {
+				err = cerr
+			}
+		}()
+	}
+	if cfg.Watch {
+		return report, watchAircraft(ctx, mongoDB.Collection("aircraft"), p, cfg.WatchTitleUpdates, cfg.WatchResumeFile)
+	}
+
+	filter := cfg.aircraftFilter()
+	var checkpoint string
+	if cfg.Incremental {
+		checkpoint, err = loadCheckpoint(ctx, mongoDB, cfg.target())
+		if err != nil {
+			return report, err
+		}
+		if checkpoint != "" {
+			filter["_id"] = bson.M{"$gt": checkpoint}
+		}
+	}
+	if cfg.streams() {
+		titleRegex := cfg.titleRegex
+		if cfg.ServerSideFilter {
+			titleRegex = nil
+		}
+		return report, streamManufacturers(ctx, p, mongoDB, cfg.readPreference(), filter, titleRegex)
+	}
+	var aircrafts []Aircraft
+	if report.Mode == modeProbe {
+		aircrafts, err = sampleAircraft(ctx, mongoDB, cfg.readPreference(), filter, cfg.Probe, report.Errors)
+	} else {
+		aircrafts, err = getAircrafts(ctx, mongoDB, cfg.readPreference(), filter, cfg.keepRaw(), report.Errors)
+	}
+	if err != nil {
+		return report, err
+	}
+	if cfg.titleRegex != nil && !cfg.ServerSideFilter {
+		aircrafts = filterByTitle(aircrafts, cfg.titleRegex)
+	}
+	out := cfg.messages()
+	color := useColor(cfg.Color, out)
+	switch report.Mode {
+	case modeDiscover:
+		discoverManufacturers(aircrafts, matcher, cfg.DiscoverTop, out)
+	case modeBaseline:
+		report.Processed = len(aircrafts)
+		report.Changed = diffBaseline(aircrafts, matcher, baseline, color, out)
+	case modeCompare:
+		report.Processed = len(aircrafts)
+		report.Mismatches, err = compareWithReference(ctx, mongoDB.Collection(cfg.CompareCollection), aircrafts, p.outcome, out)
+	case modeNormalize:
+		normalizeTitles(ctx, collection, aircrafts, matcher, cfg.DryRun, cfg.Optimistic, color, &report, out)
+	case modeProbe:
+		probe(aircrafts, matcher, color, &report, out)
+	case modeRules:
+		report.Processed = len(aircrafts)
+		withA, withB := matcher, matcher
+		withA.Rules, withB.Rules = cfg.CompareRules[0], cfg.CompareRules[1]
+		report.Changed = compareRules(aircrafts, withA, withB, color, out)
+	case modeCollision:
+		report.Processed = len(aircrafts)
+		report.Ambiguous = reportCollisions(aircrafts, matcher, cfg.CollisionDistance, out)
+	case modeVerify:
+		report.Processed = len(aircrafts)
+		report.Dangling = verifyReferences(aircrafts, manufacturers, out)
+	default:
+		if cfg.MaxUpdates > 0 && cfg.MaxUpdatesMode == maxUpdatesAbortBefore && !cfg.DryRun {
+			if n := p.plannedUpdates(aircrafts); n > cfg.MaxUpdates {
+				return report, fmt.Errorf("run would update %d aircraft, more than -max-updates %d; nothing was written", n, cfg.MaxUpdates)
+			}
+		}
+		err = addManufacturer(ctx, p, aircrafts)
+		// Aircraft that failed are retried by the next run instead of
+		// being skipped for good
+		if cfg.Incremental && !cfg.DryRun && err == nil && ctx.Err() == nil && report.Errors.total() == 0 {
+			err = saveCheckpoint(ctx, mongoDB, cfg.target(), report.RunID, checkpoint, aircrafts)
+		}
+	}
+
+	return report, err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const stateCollection = "parser_state"
+
+// parserState is where an -incremental run into Target got to. It lives in
+// the database rather than a local file so runs on any machine pick up from
+// the same place.
+type parserState struct {
+	Target    string    `bson:"_id"`
+	LastID    string    `bson:"lastId"` // Highest aircraft _id processed
+	RunID     string    `bson:"runId"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+// loadCheckpoint returns the highest aircraft _id an earlier incremental run
+// into target processed, or "" if there wasn't one
+func loadCheckpoint(ctx context.Context, db *mongo.Database, target string) (string, error) {
+	ctx, cancel := opContext(ctx)
+	defer cancel()
+	var state parserState
+	err := db.Collection(stateCollection).FindOne(ctx, bson.M{"_id": target}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil
+	}
+
+	return state.LastID, err
+}
+
+// saveCheckpoint records the highest _id among aircraft, if it's past the
+// previous checkpoint
+func saveCheckpoint(ctx context.Context, db *mongo.Database, target, runID, previous string, aircraft []Aircraft) error {
+	last := previous
+	for _, a := range aircraft {
+		last = max(last, a.ID)
+	}
+	if last == previous {
+		return nil
+	}
+	state := parserState{Target: target, LastID: last, RunID: runID, UpdatedAt: time.Now().UTC()}
+	ctx, cancel := opContext(ctx)
+	defer cancel()
+	_, err := db.Collection(stateCollection).ReplaceOne(ctx, bson.M{"_id": target}, state, mongoOptions.Replace().SetUpsert(true))
+
+	return err
+}
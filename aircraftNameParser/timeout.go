@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type opTimeoutKey struct{}
+
+// withOpTimeout makes opContext bound every database operation run under
+// the returned context to d, 0 for no bound
+func withOpTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, opTimeoutKey{}, d)
+}
+
+// opContext derives the context for a single database operation, so one
+// stuck query fails on its own instead of holding up the whole run.
+// Cancelling ctx still cancels the operation.
+func opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	d, _ := ctx.Value(opTimeoutKey{}).(time.Duration)
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, d)
+}
+
+// nextDoc advances cursor, bounding the getMore it may send like any
+// other operation
+func nextDoc(ctx context.Context, cursor *mongo.Cursor) bool {
+	opCtx, cancel := opContext(ctx)
+	defer cancel()
+
+	return cursor.Next(opCtx)
+}
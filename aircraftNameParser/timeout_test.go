@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOpContext(t *testing.T) {
+	parent, cancelParent := context.WithCancel(withOpTimeout(context.Background(), time.Minute))
+	ctx, cancel := opContext(parent)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("deadline %v, %v, want one within a minute", deadline, ok)
+	}
+	cancelParent()
+	if ctx.Err() == nil {
+		t.Error("cancelling the run didn't cancel the operation")
+	}
+
+	unbounded, cancel := opContext(withOpTimeout(context.Background(), 0))
+	defer cancel()
+	if _, ok := unbounded.Deadline(); ok {
+		t.Error("-op-timeout=0 still set a deadline")
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// verifyReferences prints every manufacturer ID an aircraft refers to that
+// isn't among the known manufacturers, with the aircraft referring to it.
+// Such references are usually left behind when a manufacturer is deleted.
+// It returns the number of aircraft with a dangling reference.
+func verifyReferences(aircraft []Aircraft, manufacturers []Manufacturer, out io.Writer) int {
+	known := make(map[string]bool, len(manufacturers))
+	for _, m := range manufacturers {
+		known[m.ID] = true
+	}
+
+	dangling := make(map[string][]string)
+	count := 0
+	for _, a := range aircraft {
+		if a.Manufacturer == "" || known[a.Manufacturer] {
+			continue
+		}
+		dangling[a.Manufacturer] = append(dangling[a.Manufacturer], a.ID)
+		count++
+	}
+	for _, id := range slices.Sorted(maps.Keys(dangling)) {
+		owners := dangling[id]
+		fmt.Fprintf(out, "unknown manufacturer %q on %d aircraft: %s\n", id, len(owners), strings.Join(owners, ", "))
+	}
+
+	return count
+}
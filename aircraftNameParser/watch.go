@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type changeEvent struct {
+	OperationType string    `bson:"operationType"`
+	FullDocument  *Aircraft `bson:"fullDocument"`
+}
+
+// echoes holds the title last written to each aircraft while watching. The
+// write is an update event of its own, which would otherwise be resolved
+// again and strip a second manufacturer off "Airbus sold to Boeing".
+type echoes map[string]string
+
+// own reports whether event only brings back a title we wrote, forgetting
+// the write either way once its aircraft changes again
+func (e echoes) own(event changeEvent) bool {
+	a := event.FullDocument
+	if event.OperationType != "update" || a == nil {
+		return false
+	}
+	title, ok := e[a.ID]
+	if !ok {
+		return false
+	}
+	delete(e, a.ID)
+
+	return title == a.Title
+}
+
+// watchAircraft resolves aircraft as they are inserted, and with titleUpdates
+// also when their title is changed, until ctx is cancelled. The stream's
+// resume token is saved to resumeFile after every event so a restart carries
+// on where the last run stopped.
+func watchAircraft(ctx context.Context, source *mongo.Collection, p *processor, titleUpdates bool, resumeFile string) error {
+	events := []bson.M{{"operationType": "insert"}}
+	if titleUpdates {
+		events = append(events, bson.M{"operationType": "update", "updateDescription.updatedFields.title": bson.M{"$exists": true}})
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"$or": events}}}}
+	opts := mongoOptions.ChangeStream().SetFullDocument(mongoOptions.UpdateLookup)
+	token, err := os.ReadFile(resumeFile)
+	if err == nil {
+		opts.SetResumeAfter(bson.Raw(token))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	opCtx, cancel := opContext(ctx)
+	stream, err := source.Watch(opCtx, pipeline, opts)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	// only in-place writes come back through the stream
+	written := echoes{}
+	inPlace := p.writer.collection.Name() == source.Name()
+	fmt.Fprintf(p.out, "watching %s for new aircraft\n", source.Name())
+	// waiting for the next change can take as long as it likes, so it
+	// isn't bounded by -op-timeout
+	for stream.Next(ctx) {
+		var event changeEvent
+		if err := stream.Decode(&event); err != nil {
+			p.errs.add("", stageDecode, err)
+		} else if a := event.FullDocument; a != nil && !written.own(event) && !p.matcher.resolve(*a).sameAs(*a) {
+			if doc, ok := stream.Current.Lookup("fullDocument").DocumentOK(); ok {
+				a.raw = slices.Clone(doc)
+			}
+			if res, ok := p.process(ctx, *a); ok {
+				fmt.Fprintf(p.out, "%s: %s %q\n", a.ID, res.Manufacturer, res.Title)
+				// an unchanged title doesn't raise the event
+				if inPlace && titleUpdates && res.Title != a.Title {
+					written[a.ID] = res.Title
+				}
+			}
+			if p.halted != nil {
+				return p.halted
+			}
+		}
+		if err := os.WriteFile(resumeFile, stream.ResumeToken(), 0o600); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return stream.Err()
+}
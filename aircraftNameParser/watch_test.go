@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestEchoesOwn(t *testing.T) {
+	m := Matcher{Manufacturers: testManufacturers(t, Manufacturer{ID: "airbus", Name: "Airbus"}, Manufacturer{ID: "boeing", Name: "Boeing"})}
+	inserted := Aircraft{ID: "1", Title: "Airbus sold to Boeing"}
+	res := m.resolve(inserted)
+	if res.Manufacturer != "airbus" || res.Title != "sold to Boeing" {
+		t.Fatalf("resolve(%q) = %s %q", inserted.Title, res.Manufacturer, res.Title)
+	}
+	written := echoes{inserted.ID: res.Title}
+
+	// the update our write causes isn't resolved again, which would strip
+	// Boeing too and overwrite airbus
+	echo := changeEvent{OperationType: "update", FullDocument: &Aircraft{ID: "1", Manufacturer: "airbus", Title: "sold to Boeing"}}
+	if !written.own(echo) {
+		t.Error("our own write wasn't recognized")
+	}
+	if written.own(echo) {
+		t.Error("the same title was skipped again after its echo")
+	}
+
+	tests := map[string]changeEvent{
+		"someone else's edit": {OperationType: "update", FullDocument: &Aircraft{ID: "1", Title: "Boeing 747"}},
+		"an insert":           {OperationType: "insert", FullDocument: &Aircraft{ID: "1", Title: "sold to Boeing"}},
+		"another aircraft":    {OperationType: "update", FullDocument: &Aircraft{ID: "2", Title: "sold to Boeing"}},
+		"no document":         {OperationType: "update"},
+	}
+	for name, event := range tests {
+		written := echoes{"1": "sold to Boeing"}
+		if written.own(event) {
+			t.Errorf("%s was taken for our own write", name)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	errKeyConflict = errors.New("key already written by another aircraft")
+	errNoSuchField = errors.New("no such aircraft field")
+	errStale       = errors.New("title changed since it was read")
+)
+
+// writer saves resolved manufacturers and titles
+type writer struct {
+	collection *mongo.Collection
+	// When set, documents are upserted into collection keyed by this aircraft
+	// field (e.g. "icaoCode") rather than updated in place by _id.
+	upsertKey string
+	// Store the manufacturer as an ObjectID reference rather than a string
+	objectIDs bool
+	// Add the manufacturers to this array field instead of setting the
+	// scalar manufacturer field
+	arrayField string
+	// When set, every in-place update is recorded here under runID
+	audit *mongo.Collection
+	runID string
+	// When set, every in-place update is also appended here
+	auditFile *auditFile
+	// When set, in-place updates are queued and sent in bulk
+	batch *batcher
+	// Only update aircraft whose title is still the one that was read
+	optimistic bool
+
+	written map[string]string // upsertKey value -> aircraft ID that wrote it
+}
+
+func newWriter(collection *mongo.Collection, cfg Config) *writer {
+	w := &writer{
+		collection: collection,
+		upsertKey:  cfg.upsertKey(),
+		objectIDs:  cfg.ManufacturerAsObjectID,
+		arrayField: cfg.ManufacturerArrayField,
+		optimistic: cfg.Optimistic,
+		written:    make(map[string]string),
+	}
+	if cfg.BatchSize > 0 {
+		w.batch = newBatcher(cfg.BatchSize, cfg.BatchSlow)
+	}
+
+	return w
+}
+
+func (w *writer) write(ctx context.Context, a Aircraft, res Resolution) error {
+	update, err := w.update(res)
+	if err != nil {
+		return err
+	}
+	if w.batch != nil {
+		w.batch.add(a.ID, update)
+		return nil
+	}
+	if w.upsertKey == "" {
+		filter := bson.M{"_id": a.ID}
+		if w.optimistic {
+			filter["title"] = a.Title
+		}
+		opCtx, cancel := opContext(ctx)
+		result, err := w.collection.UpdateOne(opCtx, filter, update)
+		cancel()
+		if err = acknowledged(err); err != nil {
+			return err
+		}
+		if w.optimistic && result.MatchedCount == 0 {
+			return errStale
+		}
+		manufacturer, err := w.reference(res.Manufacturer)
+		if err != nil {
+			return err
+		}
+		record := newAuditRecord(w.runID, a, res, manufacturer)
+		if w.audit != nil {
+			opCtx, cancel = opContext(ctx)
+			_, err = w.audit.InsertOne(opCtx, record)
+			cancel()
+			if err = acknowledged(err); err != nil {
+				return fmt.Errorf("updated but not audited: %w", err)
+			}
+		}
+		if w.auditFile != nil {
+			if err := w.auditFile.add(record); err != nil {
+				return fmt.Errorf("updated but not audited in %s: %w", w.auditFile.file.Name(), err)
+			}
+		}
+		return nil
+	}
+
+	key, err := keyValue(a, w.upsertKey)
+	if err != nil {
+		return err
+	}
+	// several aircraft sharing a key would overwrite each other, so the
+	// first one wins and the rest are reported
+	if owner, ok := w.written[key]; ok {
+		return fmt.Errorf("%s %s (from %s): %w", w.upsertKey, key, owner, errKeyConflict)
+	}
+	opCtx, cancel := opContext(ctx)
+	_, err = w.collection.UpdateOne(opCtx, bson.M{w.upsertKey: key}, update, mongoOptions.Update().SetUpsert(true))
+	cancel()
+	if err = acknowledged(err); err != nil {
+		return err
+	}
+	w.written[key] = a.ID
+
+	return nil
+}
+
+// update is the update document writing res takes
+func (w *writer) update(res Resolution) (bson.M, error) {
+	fields := bson.M{"title": res.Title}
+	if res.Model != "" {
+		fields["model"] = res.Model
+	}
+	if len(res.Parentheticals) > 0 {
+		fields["titleParentheticals"] = res.Parentheticals
+	}
+	update := bson.M{"$set": fields}
+	if w.arrayField == "" {
+		manufacturer, err := w.reference(res.Manufacturer)
+		if err != nil {
+			return nil, err
+		}
+		fields["manufacturer"] = manufacturer
+	} else {
+		// Every manufacturer found is recorded, the one picked first
+		var manufacturers []any
+		for _, id := range append([]string{res.Manufacturer}, res.AlsoMatched...) {
+			manufacturer, err := w.reference(id)
+			if err != nil {
+				return nil, err
+			}
+			manufacturers = append(manufacturers, manufacturer)
+		}
+		update["$addToSet"] = bson.M{w.arrayField: bson.M{"$each": manufacturers}}
+	}
+
+	return update, nil
+}
+
+// acknowledged drops the error the driver returns for every write made
+// with -write-concern=0, which by design never hears whether it worked
+func acknowledged(err error) error {
+	if errors.Is(err, mongo.ErrUnacknowledgedWrite) {
+		return nil
+	}
+
+	return err
+}
+
+// reference is how a manufacturer ID is stored: as is, or as an ObjectID
+func (w *writer) reference(id string) (any, error) {
+	if !w.objectIDs {
+		return id, nil
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("manufacturer ID %q is not an ObjectID", id)
+	}
+
+	return oid, nil
+}
+
+// keyValue looks up an aircraft field by its bson name
+func keyValue(a Aircraft, field string) (string, error) {
+	doc, err := bson.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	value, err := bson.Raw(doc).LookupErr(field)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, errNoSuchField)
+	}
+	key, _ := value.StringValueOK()
+	if key == "" {
+		return "", fmt.Errorf("aircraft %s has no %s", a.ID, field)
+	}
+
+	return key, nil
+}